@@ -0,0 +1,421 @@
+// Package acme obtains certificates for an app's domains from an ACME certificate authority,
+// such as Let's Encrypt, and installs and renews them on the deis platform.
+//
+// Domains are validated with HTTP-01 challenges, answered by the app itself with
+// AppHTTPProvider and ChallengeHandler, or with DNS-01 challenges published by a DNSProvider,
+// which wildcard domains require.
+//
+// To test against a local ACME server such as Pebble, point the client at Pebble's directory
+// and trust Pebble's certificate, and either let Pebble reach the app or start it with
+// PEBBLE_VA_ALWAYS_VALID=1 to skip validation:
+//
+//    client, err := acme.NewClient(<client>, "https://localhost:14000/dir", accountKey)
+//    client.ACME.HTTPClient = &http.Client{Transport: &http.Transport{
+//    	TLSClientConfig: &tls.Config{RootCAs: pebbleCA},
+//    }}
+//    client.HTTP = acme.AppHTTPProvider{Client: <client>, App: "appname"}
+//    err = client.Register(ctx, "mailto:admin@example.com")
+//    cert, err := client.Issue(ctx, "appname")
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/certs"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/domains"
+	xacme "golang.org/x/crypto/acme"
+)
+
+// LetsEncryptURL is the directory URL of Let's Encrypt's production ACME server.
+const LetsEncryptURL = xacme.LetsEncryptURL
+
+// DefaultRenewBefore is how long before expiry certificates are renewed.
+const DefaultRenewBefore = 30 * 24 * time.Hour
+
+// ErrNoChallenge is returned when a domain offers no challenge the client can fulfill, such as
+// a wildcard domain without a DNSProvider.
+var ErrNoChallenge = errors.New("no supported ACME challenge")
+
+// ErrNoDomains is returned when there is no domain to obtain a certificate for.
+var ErrNoDomains = errors.New("no domains")
+
+// ErrSeveralCerts is returned by Issue when the domains are attached to more than one
+// certificate, which a single rotation cannot replace.
+var ErrSeveralCerts = errors.New("domains are attached to several certificates")
+
+// Client obtains certificates from an ACME server and installs them on a deis controller.
+type Client struct {
+	Deis *deis.Client
+	// ACME is the client of the ACME server. Its HTTPClient can be replaced to trust a test
+	// server's certificate.
+	ACME *xacme.Client
+	// HTTP answers HTTP-01 challenges. It is used for every domain but wildcards, if set.
+	HTTP HTTPProvider
+	// DNS answers DNS-01 challenges. It is used for wildcard domains, and for every domain if
+	// HTTP is not set.
+	DNS DNSProvider
+	// RenewBefore is how long before expiry AutoRenew renews certificates.
+	// Defaults to DefaultRenewBefore.
+	RenewBefore time.Duration
+	// Issuers are the organizations or common names of the certificate authorities whose
+	// certificates AutoRenew renews, such as "Let's Encrypt", matched against the issuer of
+	// each certificate. The issuers of the certificates obtained by the client are added.
+	Issuers []string
+	// RenewAll makes AutoRenew renew every certificate, including certificates uploaded by
+	// hand or issued by another certificate authority, which are replaced with certificates
+	// of the ACME server.
+	RenewAll bool
+
+	mu       sync.Mutex
+	obtained []string
+}
+
+// NewClient creates a client for the ACME server at directoryURL, such as LetsEncryptURL,
+// with the account key accountKey. HTTP or DNS must be set before obtaining certificates.
+func NewClient(c *deis.Client, directoryURL string, accountKey crypto.Signer) (*Client, error) {
+	if accountKey == nil {
+		return nil, errors.New("acme: an account key is required")
+	}
+	return &Client{
+		Deis: c,
+		ACME: &xacme.Client{Key: accountKey, DirectoryURL: directoryURL, UserAgent: "deis-controller-sdk-go"},
+	}, nil
+}
+
+// Register creates the ACME account of the client's key, accepting the CA's terms of
+// service, with contact URLs such as mailto:admin@example.com. It does nothing if the
+// account already exists.
+func (c *Client) Register(ctx context.Context, contact ...string) error {
+	_, err := c.ACME.Register(ctx, &xacme.Account{Contact: contact}, xacme.AcceptTOS)
+	if err != nil && err != xacme.ErrAccountAlreadyExists {
+		return err
+	}
+	return nil
+}
+
+// Obtain orders a certificate for domains from the ACME server, fulfilling a challenge for
+// each domain that is not authorized yet, and returns the PEM certificate chain and a new
+// PEM private key. The first domain is the certificate's common name.
+func (c *Client) Obtain(ctx context.Context, domains []string) (string, string, error) {
+	if len(domains) == 0 {
+		return "", "", ErrNoDomains
+	}
+
+	order, err := c.ACME.AuthorizeOrder(ctx, xacme.DomainIDs(domains...))
+	if err != nil {
+		return "", "", err
+	}
+
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+	for _, u := range order.AuthzURLs {
+		authz, err := c.ACME.GetAuthorization(ctx, u)
+		if err != nil {
+			return "", "", err
+		}
+		if authz.Status == xacme.StatusValid {
+			continue
+		}
+
+		chal, cleanup, err := c.present(ctx, authz)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", authz.Identifier.Value, err)
+		}
+		cleanups = append(cleanups, cleanup)
+		if _, err := c.ACME.Accept(ctx, chal); err != nil {
+			return "", "", fmt.Errorf("%s: %w", authz.Identifier.Value, err)
+		}
+		if _, err := c.ACME.WaitAuthorization(ctx, authz.URI); err != nil {
+			return "", "", fmt.Errorf("%s: %w", authz.Identifier.Value, err)
+		}
+	}
+
+	if order, err = c.ACME.WaitOrder(ctx, order.URI); err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return "", "", err
+	}
+	chain, _, err := c.ACME.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", err
+	}
+	if leaf, err := x509.ParseCertificate(chain[0]); err == nil {
+		c.addIssuer(leaf.Issuer)
+	}
+
+	var certPEM strings.Builder
+	for _, der := range chain {
+		pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM.String(), string(keyPEM), nil
+}
+
+// present fulfills a challenge of an authorization with the client's providers. It returns
+// the challenge and a function that cleans it up.
+func (c *Client) present(ctx context.Context, authz *xacme.Authorization) (*xacme.Challenge, func(), error) {
+	domain := authz.Identifier.Value
+	for _, chal := range authz.Challenges {
+		switch {
+		case chal.Type == "http-01" && c.HTTP != nil && !authz.Wildcard:
+			keyAuth, err := c.ACME.HTTP01ChallengeResponse(chal.Token)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := c.HTTP.Present(ctx, domain, chal.Token, keyAuth); err != nil {
+				return nil, nil, err
+			}
+			return chal, func() { c.HTTP.CleanUp(ctx, domain, chal.Token, keyAuth) }, nil
+		case chal.Type == "dns-01" && c.DNS != nil && (c.HTTP == nil || authz.Wildcard):
+			value, err := c.ACME.DNS01ChallengeRecord(chal.Token)
+			if err != nil {
+				return nil, nil, err
+			}
+			fqdn := "_acme-challenge." + strings.TrimSuffix(domain, ".") + "."
+			if err := c.DNS.Present(ctx, fqdn, value); err != nil {
+				return nil, nil, err
+			}
+			return chal, func() { c.DNS.CleanUp(ctx, fqdn, value) }, nil
+		}
+	}
+	return nil, nil, ErrNoChallenge
+}
+
+// Issue obtains a certificate for domains of an app, or for every domain of the app if none is
+// given, and installs it. If a certificate is already attached to one of the domains, it is
+// replaced with certs.Rotate, and the new certificate also covers the other domains of the old
+// one. Otherwise the certificate is created with certs.New, named after the first domain with
+// certs.DefaultName. The domains are then attached to it. If the domains are attached to more
+// than one certificate, Issue returns an error matching ErrSeveralCerts without obtaining a
+// certificate; issue them separately, or detach the domains first.
+func (c *Client) Issue(ctx context.Context, appID string, names ...string) (api.Cert, error) {
+	if len(names) == 0 {
+		appDomains, err := domains.ListAll(c.Deis, appID)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return api.Cert{}, err
+		}
+		for _, d := range appDomains {
+			names = append(names, d.Domain)
+		}
+		if len(names) == 0 {
+			return api.Cert{}, fmt.Errorf("%s: %w", appID, ErrNoDomains)
+		}
+	}
+
+	existing, err := certs.ListAll(c.Deis)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Cert{}, err
+	}
+	var old *api.Cert
+	var overlapping []string
+	for i := range existing {
+		if overlaps(existing[i].Domains, names) {
+			old = &existing[i]
+			overlapping = append(overlapping, existing[i].Name)
+		}
+	}
+	if len(overlapping) > 1 {
+		sort.Strings(overlapping)
+		return api.Cert{}, fmt.Errorf("%w: %s", ErrSeveralCerts, strings.Join(overlapping, ", "))
+	}
+
+	var cert api.Cert
+	if old != nil {
+		certPEM, keyPEM, err := c.Obtain(ctx, union(names, old.Domains))
+		if err != nil {
+			return api.Cert{}, err
+		}
+		if cert, err = certs.Rotate(c.Deis, old.Name, certPEM, keyPEM); err != nil {
+			return api.Cert{}, err
+		}
+	} else {
+		certPEM, keyPEM, err := c.Obtain(ctx, names)
+		if err != nil {
+			return api.Cert{}, err
+		}
+		cert, err = certs.New(c.Deis, certPEM, keyPEM, certs.DefaultName(names[0]))
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return api.Cert{}, err
+		}
+	}
+
+	for _, domain := range names {
+		if contains(cert.Domains, domain) {
+			continue
+		}
+		if err := certs.Attach(c.Deis, cert.Name, domain); err != nil {
+			return cert, fmt.Errorf("attaching %s to %s: %w", domain, cert.Name, err)
+		}
+		cert.Domains = append(cert.Domains, domain)
+	}
+	return cert, nil
+}
+
+// Renew obtains new certificates for the names and subject alternative names of certificates,
+// and replaces them with certs.Rotate. It returns the new certificates.
+func (c *Client) Renew(ctx context.Context, names ...string) ([]api.Cert, error) {
+	renewed := []api.Cert{}
+	for _, name := range names {
+		old, err := certs.Get(c.Deis, name)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return renewed, err
+		}
+
+		var covered []string
+		for _, domain := range union(old.SubjectAltName, old.Domains) {
+			if !coveredBy(domain, covered) {
+				covered = append(covered, domain)
+			}
+		}
+		if len(covered) == 0 {
+			return renewed, fmt.Errorf("%s: %w", name, ErrNoDomains)
+		}
+
+		certPEM, keyPEM, err := c.Obtain(ctx, covered)
+		if err != nil {
+			return renewed, fmt.Errorf("%s: %w", name, err)
+		}
+		cert, err := certs.Rotate(c.Deis, name, certPEM, keyPEM)
+		if err != nil {
+			return renewed, fmt.Errorf("%s: %w", name, err)
+		}
+		renewed = append(renewed, cert)
+	}
+	return renewed, nil
+}
+
+// AutoRenew checks the certificates every interval with certs.MonitorExpiry and renews those
+// that expire within RenewBefore, until the context is done. Only the certificates of Issuers
+// and of the certificate authority the client obtained certificates from are renewed, unless
+// RenewAll is set. If apps are given, only the certificates attached to a domain of one of the
+// apps are renewed; otherwise every attached certificate is. Errors are passed to onError if it is set, and otherwise stop AutoRenew.
+// It returns the context's error, or the error that stopped it. If interval is not positive,
+// certs.DefaultMonitorInterval is used.
+func (c *Client) AutoRenew(ctx context.Context, interval time.Duration, onError func(error), apps ...string) error {
+	renewBefore := c.RenewBefore
+	if renewBefore <= 0 {
+		renewBefore = DefaultRenewBefore
+	}
+
+	return certs.MonitorExpiry(ctx, c.Deis, interval, certs.ExpiryOptions{}, func(r certs.ExpiryReport) error {
+		for _, cert := range r.Certs {
+			if cert.Expires.IsZero() || cert.Expires.Sub(r.Generated) >= renewBefore || len(cert.Domains) == 0 {
+				continue
+			}
+			if len(apps) > 0 && !overlaps(cert.Apps, apps) {
+				continue
+			}
+			if !c.RenewAll && !c.issuedBy(cert.Issuer) {
+				continue
+			}
+			if _, err := c.Renew(ctx, cert.Name); err != nil {
+				if onError == nil {
+					return err
+				}
+				onError(err)
+			}
+		}
+		return nil
+	}, onError)
+}
+
+// addIssuer adds the organization of an issuer, or its common name if it has none, to the
+// issuers whose certificates AutoRenew renews.
+func (c *Client) addIssuer(issuer pkix.Name) {
+	names := issuer.Organization
+	if len(names) == 0 {
+		names = []string{issuer.CommonName}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range names {
+		if name != "" && !contains(c.obtained, name) {
+			c.obtained = append(c.obtained, name)
+		}
+	}
+}
+
+// issuedBy reports whether issuer is one of Issuers or of the issuers of obtained certificates.
+func (c *Client) issuedBy(issuer string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range union(c.Issuers, c.obtained) {
+		if name != "" && strings.Contains(issuer, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// coveredBy reports whether one of the names covers domain, see certs.MatchDomain.
+func coveredBy(domain string, names []string) bool {
+	for _, name := range names {
+		if certs.MatchDomain(name, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// union returns a followed by the elements of b that are not in a.
+func union(a []string, b []string) []string {
+	u := append([]string{}, a...)
+	for _, s := range b {
+		if !contains(u, s) {
+			u = append(u, s)
+		}
+	}
+	return u
+}
+
+func overlaps(a []string, b []string) bool {
+	for _, s := range a {
+		if contains(b, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,716 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/certs"
+	dtime "github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/time"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/ps"
+	xacme "golang.org/x/crypto/acme"
+)
+
+type testAuthz struct {
+	domain   string
+	wildcard bool
+	status   string
+	// validated is the type of the challenge that validated the authorization.
+	validated string
+}
+
+type testOrder struct {
+	authzs []int
+	cert   []byte
+}
+
+// acmeHTTPServer is a minimal RFC 8555 server. It ignores the signatures of requests, and
+// validates HTTP-01 challenges with serve and DNS-01 challenges with records.
+type acmeHTTPServer struct {
+	mu      sync.Mutex
+	url     string
+	key     crypto.Signer
+	ca      *x509.Certificate
+	caKey   crypto.Signer
+	authzs  []*testAuthz
+	orders  []*testOrder
+	serve   func(domain string, path string) string
+	records *dnsRecords
+}
+
+func (s *acmeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	var payload []byte
+	if req.Method == "POST" {
+		body, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(body, &jws)
+		payload, _ = base64.RawURLEncoding.DecodeString(jws.Payload)
+	}
+
+	var id int
+	var kind string
+	resource := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(resource) > 1 {
+		fmt.Sscan(resource[1], &id)
+	}
+	if len(resource) > 2 {
+		kind = resource[2]
+	}
+
+	switch {
+	case req.URL.Path == "/dir":
+		fmt.Fprintf(res, `{"newNonce": "%[1]s/nonce", "newAccount": "%[1]s/account", "newOrder": "%[1]s/order",
+			"meta": {"termsOfService": "%[1]s/terms"}}`, s.url)
+	case req.URL.Path == "/nonce":
+		res.WriteHeader(http.StatusOK)
+	case req.URL.Path == "/account" && req.Method == "POST":
+		res.Header().Set("Location", s.url+"/account/1")
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(`{"status": "valid"}`))
+	case req.URL.Path == "/order" && req.Method == "POST":
+		var newOrder struct {
+			Identifiers []struct{ Value string }
+		}
+		json.Unmarshal(payload, &newOrder)
+		order := &testOrder{}
+		for _, identifier := range newOrder.Identifiers {
+			domain := strings.TrimPrefix(identifier.Value, "*.")
+			s.authzs = append(s.authzs, &testAuthz{domain: domain, wildcard: domain != identifier.Value,
+				status: xacme.StatusPending})
+			order.authzs = append(order.authzs, len(s.authzs)-1)
+		}
+		s.orders = append(s.orders, order)
+		res.Header().Set("Location", fmt.Sprintf("%s/order/%d", s.url, len(s.orders)-1))
+		res.WriteHeader(http.StatusCreated)
+		s.writeOrder(res, len(s.orders)-1)
+	case resource[0] == "order" && id < len(s.orders):
+		res.Header().Set("Location", fmt.Sprintf("%s/order/%d", s.url, id))
+		s.writeOrder(res, id)
+	case resource[0] == "authz" && id < len(s.authzs):
+		s.writeAuthz(res, id)
+	case resource[0] == "chal" && id < len(s.authzs):
+		authz := s.authzs[id]
+		token := fmt.Sprintf("token-%s-%d", kind, id)
+		keyAuth := token + "." + s.thumbprint()
+		var valid bool
+		switch kind {
+		case "http-01":
+			valid = s.serve(authz.domain, ChallengePath+token) == keyAuth
+		case "dns-01":
+			valid = s.records.get("_acme-challenge."+authz.domain+".") == dnsValue(keyAuth)
+		}
+		authz.status = xacme.StatusInvalid
+		if valid {
+			authz.status, authz.validated = xacme.StatusValid, kind
+		}
+		fmt.Fprintf(res, `{"type": %q, "url": "%s/chal/%d/%s", "token": %q, "status": %q}`,
+			kind, s.url, id, kind, token, authz.status)
+	case resource[0] == "finalize" && id < len(s.orders):
+		var finalize struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &finalize)
+		der, _ := base64.RawURLEncoding.DecodeString(finalize.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+		s.orders[id].cert, _ = x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: serial,
+			Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, s.ca, csr.PublicKey, s.caKey)
+		res.Header().Set("Location", fmt.Sprintf("%s/order/%d", s.url, id))
+		s.writeOrder(res, id)
+	case resource[0] == "cert" && id < len(s.orders) && s.orders[id].cert != nil:
+		res.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(res, &pem.Block{Type: "CERTIFICATE", Bytes: s.orders[id].cert})
+		pem.Encode(res, &pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Raw})
+	default:
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+	}
+}
+
+func (s *acmeHTTPServer) writeOrder(res http.ResponseWriter, id int) {
+	order := s.orders[id]
+	status := xacme.StatusReady
+	authzs := []string{}
+	for _, authz := range order.authzs {
+		authzs = append(authzs, fmt.Sprintf("%s/authz/%d", s.url, authz))
+		if s.authzs[authz].status != xacme.StatusValid {
+			status = xacme.StatusPending
+		}
+	}
+	cert := ""
+	if order.cert != nil {
+		status, cert = xacme.StatusValid, fmt.Sprintf("%s/cert/%d", s.url, id)
+	}
+	authzJSON, _ := json.Marshal(authzs)
+	fmt.Fprintf(res, `{"status": %q, "authorizations": %s, "finalize": "%s/finalize/%d", "certificate": %q}`,
+		status, authzJSON, s.url, id, cert)
+}
+
+func (s *acmeHTTPServer) writeAuthz(res http.ResponseWriter, id int) {
+	authz := s.authzs[id]
+	kinds := []string{"http-01", "dns-01"}
+	if authz.wildcard {
+		kinds = []string{"dns-01"}
+	}
+	challenges := []string{}
+	for _, kind := range kinds {
+		challenges = append(challenges, fmt.Sprintf(`{"type": %q, "url": "%s/chal/%d/%s", "token": "token-%s-%d"}`,
+			kind, s.url, id, kind, kind, id))
+	}
+	fmt.Fprintf(res, `{"status": %q, "identifier": {"type": "dns", "value": %q}, "wildcard": %t,
+		"challenges": [%s]}`, authz.status, authz.domain, authz.wildcard, strings.Join(challenges, ", "))
+}
+
+func (s *acmeHTTPServer) thumbprint() string {
+	thumbprint, _ := xacme.JWKThumbprint(s.key.Public())
+	return thumbprint
+}
+
+func (s *acmeHTTPServer) validated() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	validated := []string{}
+	for _, authz := range s.authzs {
+		validated = append(validated, authz.domain+" "+authz.validated)
+	}
+	return validated
+}
+
+func dnsValue(keyAuth string) string {
+	sum := sha256.Sum256([]byte(keyAuth))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// dnsRecords is a DNSProvider that keeps TXT records in memory.
+type dnsRecords struct {
+	mu      sync.Mutex
+	records map[string]string
+	cleaned []string
+}
+
+func (d *dnsRecords) Present(ctx context.Context, fqdn string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.records[fqdn] = value
+	return nil
+}
+
+func (d *dnsRecords) CleanUp(ctx context.Context, fqdn string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.records, fqdn)
+	d.cleaned = append(d.cleaned, fqdn)
+	return nil
+}
+
+func (d *dnsRecords) get(fqdn string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.records[fqdn]
+}
+
+// controllerHTTPServer is a controller with the app foo, its config, domains and certificates.
+// Setting the config creates a release, which the pods of foo run after rolloutListings more
+// listings of the pods.
+type controllerHTTPServer struct {
+	mu         sync.Mutex
+	domains    []string
+	config     map[string]string
+	configSets int
+	certs      map[string]*api.Cert
+	// version is the latest release, running the release of the pods, which serve the
+	// thumbprint served.
+	version, running int
+	served           string
+	rolloutListings  int
+	pendingListings  int
+}
+
+func (s *controllerHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+	body, _ := ioutil.ReadAll(req.Body)
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/certs/")
+	name, domain, attach := strings.Cut(path, "/domain/")
+	name = strings.TrimSuffix(name, "/")
+	cert, exists := s.certs[name]
+
+	switch {
+	case req.URL.Path == "/v2/apps/" && req.Method == "GET":
+		res.Write([]byte(`{"count": 1, "results": [{"id": "foo"}]}`))
+	case req.URL.Path == "/v2/apps/foo/domains/" && req.Method == "GET":
+		results := []string{}
+		for _, domain := range s.domains {
+			results = append(results, fmt.Sprintf(`{"app": "foo", "domain": %q}`, domain))
+		}
+		fmt.Fprintf(res, `{"count": %d, "results": [%s]}`, len(results), strings.Join(results, ", "))
+	case req.URL.Path == "/v2/apps/foo/config/" && req.Method == "GET":
+		values, _ := json.Marshal(s.config)
+		fmt.Fprintf(res, `{"app": "foo", "values": %s}`, values)
+	case req.URL.Path == "/v2/apps/foo/config/" && req.Method == "POST":
+		set := api.ConfigSet{}
+		json.Unmarshal(body, &set)
+		for key, value := range set.Values {
+			s.config[key] = value
+		}
+		s.configSets++
+		s.version++
+		s.pendingListings = s.rolloutListings
+		res.WriteHeader(http.StatusCreated)
+		values, _ := json.Marshal(s.config)
+		fmt.Fprintf(res, `{"app": "foo", "values": %s}`, values)
+	case req.URL.Path == "/v2/apps/foo/releases/" && req.Method == "GET":
+		fmt.Fprintf(res, `{"count": 1, "results": [{"app": "foo", "version": %d}]}`, s.version)
+	case req.URL.Path == "/v2/apps/foo/pods/" && req.Method == "GET":
+		if s.running != s.version {
+			if s.pendingListings > 0 {
+				s.pendingListings--
+			} else {
+				s.running, s.served = s.version, s.config[ThumbprintVar]
+			}
+		}
+		fmt.Fprintf(res, `{"count": 1, "results": [{"release": "v%d", "type": "web", "name": "foo-web-1",
+			"state": "up"}]}`, s.running)
+	case req.URL.Path == "/v2/certs/" && req.Method == "GET":
+		results := []string{}
+		for _, cert := range s.certs {
+			results = append(results, certJSON(cert))
+		}
+		fmt.Fprintf(res, `{"count": %d, "results": [%s]}`, len(results), strings.Join(results, ", "))
+	case path == "" && req.Method == "POST":
+		create := api.CertCreateRequest{}
+		json.Unmarshal(body, &create)
+		info, err := certs.Validate(create.Certificate, create.Key, certs.ValidateOptions{})
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(res, `{"detail": %q}`, err)
+			return
+		}
+		s.certs[create.Name] = &api.Cert{Name: create.Name, CommonName: info.CommonName, Issuer: info.Issuer,
+			Expires: dtime.Time{Time: &info.NotAfter}, SubjectAltName: info.SubjectAltNames, Domains: []string{}}
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(certJSON(s.certs[create.Name])))
+	case exists && !attach && req.Method == "GET":
+		res.Write([]byte(certJSON(cert)))
+	case exists && !attach && req.Method == "DELETE":
+		delete(s.certs, name)
+		res.WriteHeader(http.StatusNoContent)
+	case exists && attach && domain == "" && req.Method == "POST":
+		attachReq := api.CertAttachRequest{}
+		json.Unmarshal(body, &attachReq)
+		cert.Domains = append(cert.Domains, attachReq.Domain)
+		res.WriteHeader(http.StatusCreated)
+	case exists && attach && domain != "" && req.Method == "DELETE":
+		domains := []string{}
+		for _, d := range cert.Domains {
+			if d != domain {
+				domains = append(domains, d)
+			}
+		}
+		cert.Domains = domains
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+	}
+}
+
+// certJSON encodes the fields of a certificate the fake controller keeps.
+func certJSON(cert *api.Cert) string {
+	fields := map[string]interface{}{"name": cert.Name, "common_name": cert.CommonName, "issuer": cert.Issuer,
+		"san": cert.SubjectAltName, "domains": cert.Domains}
+	if cert.Expires.Time != nil {
+		fields["expires"] = cert.Expires.Time.Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+func (s *controllerHTTPServer) state() (map[string][]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := map[string][]string{}
+	for name, cert := range s.certs {
+		state[name] = append([]string{}, cert.Domains...)
+		sort.Strings(state[name])
+	}
+	return state, s.configSets
+}
+
+// thumbprint returns the thumbprint served by the pods of foo.
+func (s *controllerHTTPServer) thumbprint() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.served
+}
+
+type testServers struct {
+	controller *controllerHTTPServer
+	acme       *acmeHTTPServer
+	client     *Client
+	roots      *x509.CertPool
+}
+
+// newTestServers starts a controller and an ACME server, whose HTTP-01 challenges are
+// answered by ChallengeHandler with the thumbprint in the app's config, as the app would.
+func newTestServers(t *testing.T) (*testServers, func()) {
+	controller := &controllerHTTPServer{domains: []string{"example.com", "www.example.com"},
+		config: map[string]string{}, certs: map[string]*api.Cert{}, version: 1, running: 1}
+	controllerServer := httptest.NewServer(controller)
+
+	s := &acmeHTTPServer{records: &dnsRecords{records: map[string]string{}}}
+	s.serve = func(domain string, path string) string {
+		rec := httptest.NewRecorder()
+		ChallengeHandler(controller.thumbprint()).ServeHTTP(rec, httptest.NewRequest("GET", "http://"+domain+path, nil))
+		return rec.Body.String()
+	}
+	acmeServer := httptest.NewServer(s)
+	s.url = acmeServer.URL
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test ACME CA"}}, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatal(err)
+	}
+	s.caKey = caKey
+	roots := x509.NewCertPool()
+	roots.AddCert(s.ca)
+
+	deisClient, err := deis.New(false, controllerServer.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.key = accountKey
+	client, err := NewClient(deisClient, acmeServer.URL+"/dir", accountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTP = AppHTTPProvider{Client: deisClient, App: "foo", PollInterval: time.Millisecond}
+	if err := client.Register(context.Background(), "mailto:admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServers{controller: controller, acme: s, client: client, roots: roots}, func() {
+		controllerServer.Close()
+		acmeServer.Close()
+	}
+}
+
+func TestIssue(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	cert, err := ts.client.Issue(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Name != "example-com" || !reflect.DeepEqual([]string{"example.com", "www.example.com"}, cert.Domains) {
+		t.Errorf("Unexpected certificate %+v", cert)
+	}
+
+	state, configSets := ts.controller.state()
+	expected := map[string][]string{"example-com": {"example.com", "www.example.com"}}
+	if !reflect.DeepEqual(expected, state) {
+		t.Errorf("Expected %v, Got %v", expected, state)
+	}
+	if configSets != 1 || ts.controller.thumbprint() != ts.acme.thumbprint() {
+		t.Errorf("Expected the thumbprint to be set once, Got %d sets of %q", configSets, ts.controller.thumbprint())
+	}
+
+	validated := []string{"example.com http-01", "www.example.com http-01"}
+	if actual := ts.acme.validated(); !reflect.DeepEqual(validated, actual) {
+		t.Errorf("Expected %v, Got %v", validated, actual)
+	}
+
+	// Issuing again replaces the certificate, without setting the thumbprint again.
+	cert, err = ts.client.Issue(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cert.Name, "example-com-") {
+		t.Errorf("Expected a rotated certificate, Got %s", cert.Name)
+	}
+	state, configSets = ts.controller.state()
+	expected = map[string][]string{cert.Name: {"example.com", "www.example.com"}}
+	if !reflect.DeepEqual(expected, state) || configSets != 1 {
+		t.Errorf("Expected %v and 1 config set, Got %v and %d", expected, state, configSets)
+	}
+}
+
+func TestIssueSlowRollout(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+	ts.controller.rolloutListings = 5
+
+	// The challenges are only accepted once the pods serve the thumbprint.
+	if _, err := ts.client.Issue(context.Background(), "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if ts.controller.thumbprint() != ts.acme.thumbprint() {
+		t.Errorf("Expected the pods to serve %q, Got %q", ts.acme.thumbprint(), ts.controller.thumbprint())
+	}
+
+	// The context bounds the wait for the rollout.
+	ts.controller.mu.Lock()
+	ts.controller.config[ThumbprintVar] = "previous"
+	ts.controller.rolloutListings = 1000
+	ts.controller.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := ts.client.Issue(ctx, "foo", "www.example.com")
+	notReady := ps.NotReadyError{}
+	if !errors.As(err, &notReady) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a ps.NotReadyError, Got %v", err)
+	}
+}
+
+func TestIssueSeveralCerts(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	for _, domain := range []string{"example.com", "www.example.com"} {
+		if _, err := ts.client.Issue(context.Background(), "foo", domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := ts.client.Issue(context.Background(), "foo")
+	if !errors.Is(err, ErrSeveralCerts) || !strings.HasSuffix(err.Error(), "example-com, www-example-com") {
+		t.Errorf("Expected %v, Got %v", ErrSeveralCerts, err)
+	}
+
+	state, _ := ts.controller.state()
+	expected := map[string][]string{"example-com": {"example.com"}, "www-example-com": {"www.example.com"}}
+	if !reflect.DeepEqual(expected, state) {
+		t.Errorf("Expected %v, Got %v", expected, state)
+	}
+}
+
+func TestIssueWildcard(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	_, err := ts.client.Issue(context.Background(), "foo", "*.example.com")
+	if !errors.Is(err, ErrNoChallenge) {
+		t.Errorf("Expected %v, Got %v", ErrNoChallenge, err)
+	}
+
+	ts.client.DNS = ts.acme.records
+	cert, err := ts.client.Issue(context.Background(), "foo", "*.example.com", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Name != "wildcard-example-com" {
+		t.Errorf("Expected wildcard-example-com, Got %s", cert.Name)
+	}
+
+	validated := []string{"example.com ", "example.com dns-01", "example.com http-01"}
+	if actual := ts.acme.validated(); !reflect.DeepEqual(validated, actual) {
+		t.Errorf("Expected %v, Got %v", validated, actual)
+	}
+	cleaned := []string{"_acme-challenge.example.com."}
+	if !reflect.DeepEqual(cleaned, ts.acme.records.cleaned) || len(ts.acme.records.records) != 0 {
+		t.Errorf("Expected %v to be cleaned up, Got %v", cleaned, ts.acme.records.cleaned)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	if _, err := ts.client.Issue(context.Background(), "foo", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := ts.client.Renew(context.Background(), "example-com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renewed) != 1 || !strings.HasPrefix(renewed[0].Name, "example-com-") {
+		t.Fatalf("Expected a rotated certificate, Got %+v", renewed)
+	}
+
+	state, _ := ts.controller.state()
+	expected := map[string][]string{renewed[0].Name: {"example.com"}}
+	if !reflect.DeepEqual(expected, state) {
+		t.Errorf("Expected %v, Got %v", expected, state)
+	}
+
+	if _, err := ts.client.Renew(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error renewing a missing certificate")
+	}
+}
+
+func TestAutoRenew(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	if _, err := ts.client.Issue(context.Background(), "foo", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// www.example.com has a certificate of another CA, uploaded by hand.
+	expires := time.Now().Add(24 * time.Hour)
+	ts.controller.mu.Lock()
+	ts.controller.certs["www-example-com"] = &api.Cert{Name: "www-example-com", CommonName: "www.example.com",
+		Issuer: "/C=US/O=Commercial CA/CN=Commercial CA", Expires: dtime.Time{Time: &expires},
+		Domains: []string{"www.example.com"}}
+	ts.controller.mu.Unlock()
+
+	// Every certificate expires within RenewBefore. The first check runs at once.
+	ts.client.RenewBefore = 365 * 24 * time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := ts.client.AutoRenew(ctx, time.Hour, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	state, _ := ts.controller.state()
+	if len(state) != 2 || !reflect.DeepEqual([]string{"www.example.com"}, state["www-example-com"]) {
+		t.Fatalf("Expected the certificate of www.example.com to be left alone, Got %v", state)
+	}
+	for name, domains := range state {
+		if name != "www-example-com" && (!strings.HasPrefix(name, "example-com-") ||
+			!reflect.DeepEqual([]string{"example.com"}, domains)) {
+			t.Errorf("Expected example-com to be renewed, Got %s %v", name, domains)
+		}
+	}
+
+	// With RenewAll, the other certificate is replaced too.
+	ts.client.RenewAll = true
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := ts.client.AutoRenew(ctx, time.Hour, nil, "foo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+	if state, _ = ts.controller.state(); state["www-example-com"] != nil {
+		t.Errorf("Expected www-example-com to be renewed, Got %v", state)
+	}
+}
+
+func TestObtain(t *testing.T) {
+	t.Parallel()
+
+	ts, stop := newTestServers(t)
+	defer stop()
+
+	certPEM, keyPEM, err := ts.client.Obtain(context.Background(), []string{"www.example.com", "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := certs.Validate(certPEM, keyPEM, certs.ValidateOptions{Roots: ts.roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CommonName != "www.example.com" || !info.Covers("example.com") {
+		t.Errorf("Unexpected certificate %+v", info)
+	}
+
+	if _, _, err := ts.client.Obtain(context.Background(), nil); !errors.Is(err, ErrNoDomains) {
+		t.Errorf("Expected %v, Got %v", ErrNoDomains, err)
+	}
+}
+
+func TestChallengeHandler(t *testing.T) {
+	t.Setenv(ThumbprintVar, "env-thumbprint")
+
+	tests := []struct {
+		thumbprint string
+		path       string
+		expected   string
+	}{
+		{"thumbprint", ChallengePath + "abc_DEF-1", "abc_DEF-1.thumbprint"},
+		{"", ChallengePath + "abc", "abc.env-thumbprint"},
+		{"thumbprint", ChallengePath, ""},
+		{"thumbprint", ChallengePath + "a/b", ""},
+		{"thumbprint", "/abc", ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		ChallengeHandler(test.thumbprint).ServeHTTP(rec, httptest.NewRequest("GET", test.path, nil))
+		if test.expected == "" && rec.Code != http.StatusNotFound {
+			t.Errorf("%s: Expected 404, Got %d", test.path, rec.Code)
+		} else if test.expected != "" && rec.Body.String() != test.expected {
+			t.Errorf("%s: Expected %q, Got %q", test.path, test.expected, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/ps"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/releases"
+)
+
+// ThumbprintVar is the config var AppHTTPProvider sets to the thumbprint of the ACME account
+// key, from which ChallengeHandler answers HTTP-01 challenges.
+const ThumbprintVar = "ACME_ACCOUNT_THUMBPRINT"
+
+// ChallengePath is the path prefix under which HTTP-01 challenges are served.
+const ChallengePath = "/.well-known/acme-challenge/"
+
+// HTTPProvider makes the key authorization of an HTTP-01 challenge available at
+// http://<domain>/.well-known/acme-challenge/<token>.
+type HTTPProvider interface {
+	// Present serves keyAuth for token on domain.
+	Present(ctx context.Context, domain string, token string, keyAuth string) error
+	// CleanUp stops serving token once the challenge is validated or failed.
+	CleanUp(ctx context.Context, domain string, token string, keyAuth string) error
+}
+
+// DNSProvider publishes the TXT record of a DNS-01 challenge. Implementations wrap the API of
+// the DNS hosting the domains, and are required for wildcard domains.
+type DNSProvider interface {
+	// Present creates a TXT record named fqdn, such as _acme-challenge.example.com., with value.
+	Present(ctx context.Context, fqdn string, value string) error
+	// CleanUp deletes the TXT record once the challenge is validated or failed.
+	CleanUp(ctx context.Context, fqdn string, value string) error
+}
+
+// AppHTTPProvider answers HTTP-01 challenges from the app the domains are attached to. The key
+// authorization of a challenge is its token and the account key's thumbprint, so the app can
+// answer every challenge of the account once it knows the thumbprint: Present sets it as the
+// ThumbprintVar config var of the app, and the app serves ChallengeHandler.
+//
+// Setting the config var creates a release, so it is only set when it changed, that is on the
+// first challenge of an account, and Present then waits for the pods of the app to run the
+// release before the challenge is accepted.
+type AppHTTPProvider struct {
+	Client *deis.Client
+	App    string
+	// PollInterval is the interval between two checks of the rollout of the release that sets
+	// the thumbprint. Defaults to ps.DefaultPollInterval.
+	PollInterval time.Duration
+}
+
+// Present sets the app's ThumbprintVar config var to the thumbprint of keyAuth if needed, and
+// waits until the app's pods run the release it creates or the context is done.
+func (p AppHTTPProvider) Present(ctx context.Context, domain string, token string, keyAuth string) error {
+	thumbprint := strings.TrimPrefix(keyAuth, token+".")
+
+	current, err := config.List(p.Client, p.App)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return err
+	}
+	if value, ok := current.Values[ThumbprintVar].(string); ok && value == thumbprint {
+		return nil
+	}
+
+	latest, _, err := releases.List(p.Client, p.App, 1)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return err
+	}
+	after := 0
+	if len(latest) > 0 {
+		after = latest[0].Version
+	}
+
+	_, err = config.Set(p.Client, p.App, api.Config{Values: map[string]interface{}{ThumbprintVar: thumbprint}})
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return err
+	}
+
+	release, err := releases.WaitForRelease(ctx, p.Client, p.App, releases.WatchOptions{
+		After:        after,
+		PollInterval: p.PollInterval,
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for the release setting %s: %w", ThumbprintVar, err)
+	}
+	if _, err := ps.WaitForVersion(ctx, p.Client, p.App, release.Version, p.PollInterval); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CleanUp does nothing, the thumbprint is kept for the next challenges of the account.
+func (p AppHTTPProvider) CleanUp(ctx context.Context, domain string, token string, keyAuth string) error {
+	return nil
+}
+
+// ChallengeHandler returns the handler that answers HTTP-01 challenges under ChallengePath for
+// an app using AppHTTPProvider. If thumbprint is empty, the ThumbprintVar environment variable
+// is used.
+//
+// This example serves challenges next to the app's own routes:
+//
+//    mux := http.NewServeMux()
+//    mux.Handle(acme.ChallengePath, acme.ChallengeHandler(""))
+//    mux.Handle("/", app)
+func ChallengeHandler(thumbprint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := thumbprint
+		if current == "" {
+			current = os.Getenv(ThumbprintVar)
+		}
+		token := strings.TrimPrefix(r.URL.Path, ChallengePath)
+		if current == "" || token == r.URL.Path || !validToken(token) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(token + "." + current))
+	})
+}
+
+// validToken reports whether token is a non-empty base64url string, as ACME tokens are.
+func validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, r := range token {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,457 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Probe types of a Healthchecks map.
+const (
+	LivenessProbe  = "livenessProbe"
+	ReadinessProbe = "readinessProbe"
+)
+
+// Probe handlers accepted by ParseHealthcheck.
+const (
+	HTTPGetHandler   = "httpGet"
+	TCPSocketHandler = "tcpSocket"
+	ExecHandler      = "exec"
+)
+
+// Default healthcheck settings, matching the ones used by the deis client.
+const (
+	DefaultInitialDelaySeconds = 50
+	DefaultTimeoutSeconds      = 50
+	DefaultPeriodSeconds       = 10
+	DefaultSuccessThreshold    = 1
+	DefaultFailureThreshold    = 3
+)
+
+// ErrInvalidHealthcheck is returned when a healthcheck fails validation or cannot be parsed.
+var ErrInvalidHealthcheck = errors.New("invalid healthcheck")
+
+func invalidHealthcheck(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidHealthcheck, fmt.Sprintf(format, args...))
+}
+
+// Validate checks that exactly one probe handler is set, that ports are valid and that
+// delays, periods, timeouts and thresholds are not negative. Zero values are unset, and the
+// cluster applies its defaults to them.
+func (h Healthcheck) Validate() error {
+	handlers := 0
+	if h.HTTPGet != nil {
+		handlers++
+		if err := validPort(h.HTTPGet.Port); err != nil {
+			return err
+		}
+		if h.HTTPGet.Path != "" && !strings.HasPrefix(h.HTTPGet.Path, "/") {
+			return invalidHealthcheck("path %q must start with /", h.HTTPGet.Path)
+		}
+		for _, header := range h.HTTPGet.HTTPHeaders {
+			if header == nil || header.Name == "" {
+				return invalidHealthcheck("header names must not be empty")
+			}
+		}
+	}
+	if h.TCPSocket != nil {
+		handlers++
+		if err := validPort(h.TCPSocket.Port); err != nil {
+			return err
+		}
+	}
+	if h.Exec != nil {
+		handlers++
+		if len(h.Exec.Command) == 0 {
+			return invalidHealthcheck("exec command must not be empty")
+		}
+	}
+	if handlers != 1 {
+		return invalidHealthcheck("exactly one of httpGet, tcpSocket or exec must be set, got %d", handlers)
+	}
+
+	if h.InitialDelaySeconds < 0 {
+		return invalidHealthcheck("initial delay must not be negative, got %d", h.InitialDelaySeconds)
+	}
+	if h.TimeoutSeconds < 0 {
+		return invalidHealthcheck("timeout must not be negative, got %d", h.TimeoutSeconds)
+	}
+	if h.PeriodSeconds < 0 {
+		return invalidHealthcheck("period must not be negative, got %d", h.PeriodSeconds)
+	}
+	if h.SuccessThreshold < 0 {
+		return invalidHealthcheck("success threshold must not be negative, got %d", h.SuccessThreshold)
+	}
+	if h.FailureThreshold < 0 {
+		return invalidHealthcheck("failure threshold must not be negative, got %d", h.FailureThreshold)
+	}
+	return nil
+}
+
+func validPort(port int) error {
+	if port < 1 || port > 65535 {
+		return invalidHealthcheck("port must be between 1 and 65535, got %d", port)
+	}
+	return nil
+}
+
+// Validate checks that the map only holds liveness and readiness probes and that each of them
+// is valid. Liveness probes must have a success threshold of 1, or leave it unset. Probes set
+// to nil, which unset them, are ignored.
+func (h Healthchecks) Validate() error {
+	probes := make([]string, 0, len(h))
+	for probe := range h {
+		probes = append(probes, probe)
+	}
+	sort.Strings(probes)
+
+	for _, probe := range probes {
+		check := h[probe]
+		if probe != LivenessProbe && probe != ReadinessProbe {
+			return invalidHealthcheck("unknown probe type %q, must be %s or %s", probe, LivenessProbe, ReadinessProbe)
+		}
+		if check == nil {
+			continue
+		}
+		if err := check.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", probe, err)
+		}
+		if probe == LivenessProbe && check.SuccessThreshold > 1 {
+			return invalidHealthcheck("%s: success threshold must be 1, got %d", probe, check.SuccessThreshold)
+		}
+	}
+	return nil
+}
+
+// ValidateHealthchecks validates the healthchecks of every process type in the config.
+func (c Config) ValidateHealthchecks() error {
+	procTypes := make([]string, 0, len(c.Healthcheck))
+	for procType := range c.Healthcheck {
+		procTypes = append(procTypes, procType)
+	}
+	sort.Strings(procTypes)
+
+	for _, procType := range procTypes {
+		if checks := c.Healthcheck[procType]; checks != nil {
+			if err := checks.Validate(); err != nil {
+				return fmt.Errorf("healthcheck for %s: %w", procType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetHealthcheck validates a healthcheck and sets it as the liveness or readiness probe of
+// a process type.
+func (c *Config) SetHealthcheck(procType string, probe string, h *Healthcheck) error {
+	if err := (Healthchecks{probe: h}).Validate(); err != nil {
+		return err
+	}
+	if c.Healthcheck == nil {
+		c.Healthcheck = make(map[string]*Healthchecks)
+	}
+	if c.Healthcheck[procType] == nil {
+		c.Healthcheck[procType] = &Healthchecks{}
+	}
+	(*c.Healthcheck[procType])[probe] = h
+	return nil
+}
+
+// HealthcheckBuilder builds a Healthcheck one setting at a time, starting from the defaults.
+//
+//    h, err := api.NewHTTPGetHealthcheck("/health", 5000).
+//        Header("X-Probe", "liveness").
+//        InitialDelay(5).
+//        Period(10).
+//        Build()
+type HealthcheckBuilder struct {
+	h Healthcheck
+}
+
+func newHealthcheckBuilder() *HealthcheckBuilder {
+	return &HealthcheckBuilder{h: Healthcheck{
+		InitialDelaySeconds: DefaultInitialDelaySeconds,
+		TimeoutSeconds:      DefaultTimeoutSeconds,
+		PeriodSeconds:       DefaultPeriodSeconds,
+		SuccessThreshold:    DefaultSuccessThreshold,
+		FailureThreshold:    DefaultFailureThreshold,
+	}}
+}
+
+// NewHTTPGetHealthcheck starts building a probe that sends an HTTP GET request to path and port.
+func NewHTTPGetHealthcheck(path string, port int) *HealthcheckBuilder {
+	b := newHealthcheckBuilder()
+	b.h.HTTPGet = &HTTPGetProbe{Path: path, Port: port}
+	return b
+}
+
+// NewTCPSocketHealthcheck starts building a probe that opens a TCP connection to port.
+func NewTCPSocketHealthcheck(port int) *HealthcheckBuilder {
+	b := newHealthcheckBuilder()
+	b.h.TCPSocket = &TCPSocketProbe{Port: port}
+	return b
+}
+
+// NewExecHealthcheck starts building a probe that runs a command in the container.
+func NewExecHealthcheck(command ...string) *HealthcheckBuilder {
+	b := newHealthcheckBuilder()
+	b.h.Exec = &ExecProbe{Command: command}
+	return b
+}
+
+// Header adds an HTTP header to an httpGet probe. It has no effect on other probes.
+func (b *HealthcheckBuilder) Header(name string, value string) *HealthcheckBuilder {
+	if b.h.HTTPGet != nil {
+		b.h.HTTPGet.HTTPHeaders = append(b.h.HTTPGet.HTTPHeaders, &KVPair{Name: name, Value: value})
+	}
+	return b
+}
+
+// InitialDelay sets the number of seconds after the container starts before probes begin.
+func (b *HealthcheckBuilder) InitialDelay(seconds int) *HealthcheckBuilder {
+	b.h.InitialDelaySeconds = seconds
+	return b
+}
+
+// Timeout sets the number of seconds after which a probe times out.
+func (b *HealthcheckBuilder) Timeout(seconds int) *HealthcheckBuilder {
+	b.h.TimeoutSeconds = seconds
+	return b
+}
+
+// Period sets how often, in seconds, the probe runs.
+func (b *HealthcheckBuilder) Period(seconds int) *HealthcheckBuilder {
+	b.h.PeriodSeconds = seconds
+	return b
+}
+
+// SuccessThreshold sets the number of consecutive successes after a failure for the probe
+// to be considered successful.
+func (b *HealthcheckBuilder) SuccessThreshold(n int) *HealthcheckBuilder {
+	b.h.SuccessThreshold = n
+	return b
+}
+
+// FailureThreshold sets the number of consecutive failures for the probe to be considered failed.
+func (b *HealthcheckBuilder) FailureThreshold(n int) *HealthcheckBuilder {
+	b.h.FailureThreshold = n
+	return b
+}
+
+// Build validates and returns the healthcheck. Unlike Validate, it also rejects a timeout,
+// period or threshold set to zero, since the builder starts from the defaults.
+func (b *HealthcheckBuilder) Build() (*Healthcheck, error) {
+	h := b.h
+	if h.HTTPGet != nil {
+		probe := *h.HTTPGet
+		probe.HTTPHeaders = append([]*KVPair(nil), probe.HTTPHeaders...)
+		h.HTTPGet = &probe
+	}
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+	if h.TimeoutSeconds == 0 || h.PeriodSeconds == 0 || h.SuccessThreshold == 0 || h.FailureThreshold == 0 {
+		return nil, invalidHealthcheck("timeout, period and thresholds must be positive")
+	}
+	return &h, nil
+}
+
+// ParseHealthcheck parses a healthcheck from a compact command line syntax:
+//
+//    httpGet [path] <port> [--headers Name=Value[,Name=Value...]]
+//    tcpSocket <port>
+//    exec <command> [args...]
+//
+// followed by any of the options --initial-delay, --timeout, --period, --success-threshold
+// and --failure-threshold, which take a number of seconds or a count as in "--period 10" or
+// "--period=10". The deis client's option names --initial-delay-timeout and --period-seconds
+// are also accepted. Headers may also be given as Name:Value. Options must come before the
+// command of an exec probe. Arguments may be quoted with single or double quotes.
+//
+//    h, err := api.ParseHealthcheck("httpGet /health 5000 --headers X-Probe=1 --period 5")
+func ParseHealthcheck(s string) (*Healthcheck, error) {
+	args, err := splitArgs(s)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHealthcheckArgs(args)
+}
+
+// ParseHealthcheckArgs is like ParseHealthcheck, but takes arguments that are already split,
+// such as os.Args.
+func ParseHealthcheckArgs(args []string) (*Healthcheck, error) {
+	if len(args) == 0 {
+		return nil, invalidHealthcheck("missing probe handler, must be %s, %s or %s",
+			HTTPGetHandler, TCPSocketHandler, ExecHandler)
+	}
+
+	handler := args[0]
+	var positional []string
+	b := newHealthcheckBuilder()
+	var headers []*KVPair
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		if handler == ExecHandler && len(positional) > 0 {
+			positional = append(positional, arg)
+			continue
+		}
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, invalidHealthcheck("option --%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == "headers" {
+			parsed, err := parseHeaders(value)
+			if err != nil {
+				return nil, err
+			}
+			headers = append(headers, parsed...)
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidHealthcheck("option --%s requires a number, got %q", name, value)
+		}
+		switch name {
+		case "initial-delay", "initial-delay-timeout":
+			b.InitialDelay(n)
+		case "timeout":
+			b.Timeout(n)
+		case "period", "period-seconds":
+			b.Period(n)
+		case "success-threshold":
+			b.SuccessThreshold(n)
+		case "failure-threshold":
+			b.FailureThreshold(n)
+		default:
+			return nil, invalidHealthcheck("unknown option --%s", name)
+		}
+	}
+
+	switch handler {
+	case HTTPGetHandler:
+		path, portArg := "/", ""
+		switch len(positional) {
+		case 1:
+			portArg = positional[0]
+		case 2:
+			path, portArg = positional[0], positional[1]
+		default:
+			return nil, invalidHealthcheck("usage: httpGet [path] <port> [--headers Name=Value]")
+		}
+		port, err := parsePort(portArg)
+		if err != nil {
+			return nil, err
+		}
+		b.h.HTTPGet = &HTTPGetProbe{Path: path, Port: port, HTTPHeaders: headers}
+	case TCPSocketHandler:
+		if len(positional) != 1 {
+			return nil, invalidHealthcheck("usage: tcpSocket <port>")
+		}
+		port, err := parsePort(positional[0])
+		if err != nil {
+			return nil, err
+		}
+		b.h.TCPSocket = &TCPSocketProbe{Port: port}
+	case ExecHandler:
+		if len(positional) == 0 {
+			return nil, invalidHealthcheck("usage: exec <command> [args...]")
+		}
+		b.h.Exec = &ExecProbe{Command: positional}
+	default:
+		return nil, invalidHealthcheck("unknown probe handler %q, must be %s, %s or %s",
+			handler, HTTPGetHandler, TCPSocketHandler, ExecHandler)
+	}
+
+	if len(headers) > 0 && handler != HTTPGetHandler {
+		return nil, invalidHealthcheck("--headers is only valid for %s probes", HTTPGetHandler)
+	}
+
+	return b.Build()
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, invalidHealthcheck("port must be a number, got %q", s)
+	}
+	return port, validPort(port)
+}
+
+// parseHeaders parses a comma separated list of Name=Value or Name:Value headers.
+func parseHeaders(s string) ([]*KVPair, error) {
+	var headers []*KVPair
+	for _, h := range strings.Split(s, ",") {
+		i := strings.IndexAny(h, "=:")
+		if i <= 0 {
+			return nil, invalidHealthcheck("header %q must be Name=Value", h)
+		}
+		headers = append(headers, &KVPair{
+			Name:  strings.TrimSpace(h[:i]),
+			Value: strings.TrimSpace(h[i+1:]),
+		})
+	}
+	return headers, nil
+}
+
+// splitArgs splits a string into arguments on whitespace, honoring single and double quotes
+// and backslash escapes outside of single quotes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != '\'' && r == '\\' && i+1 < len(runes):
+			i++
+			cur.WriteRune(runes[i])
+			inArg = true
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, invalidHealthcheck("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
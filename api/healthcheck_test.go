@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHealthcheckBuilder(t *testing.T) {
+	t.Parallel()
+
+	actual, err := NewHTTPGetHealthcheck("/health", 5000).
+		Header("X-Probe", "liveness").
+		InitialDelay(5).
+		Timeout(3).
+		Period(15).
+		SuccessThreshold(1).
+		FailureThreshold(4).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Healthcheck{
+		InitialDelaySeconds: 5,
+		TimeoutSeconds:      3,
+		PeriodSeconds:       15,
+		SuccessThreshold:    1,
+		FailureThreshold:    4,
+		HTTPGet: &HTTPGetProbe{
+			Path:        "/health",
+			Port:        5000,
+			HTTPHeaders: []*KVPair{{Name: "X-Probe", Value: "liveness"}},
+		},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	tcp, err := NewTCPSocketHealthcheck(6379).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tcp.TCPSocket.Port != 6379 || tcp.PeriodSeconds != DefaultPeriodSeconds {
+		t.Errorf("Expected a tcpSocket probe with default settings, Got %v", tcp)
+	}
+
+	if _, err := NewExecHealthcheck().Build(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidHealthcheck, err)
+	}
+
+	if _, err := NewTCPSocketHealthcheck(70000).Build(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidHealthcheck, err)
+	}
+
+	if _, err := NewTCPSocketHealthcheck(80).Period(0).Build(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidHealthcheck, err)
+	}
+}
+
+func TestHealthcheckValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := Healthcheck{TimeoutSeconds: 1, PeriodSeconds: 1, SuccessThreshold: 1, FailureThreshold: 1}
+
+	both := valid
+	both.HTTPGet = &HTTPGetProbe{Port: 80}
+	both.TCPSocket = &TCPSocketProbe{Port: 80}
+
+	badPath := valid
+	badPath.HTTPGet = &HTTPGetProbe{Path: "health", Port: 80}
+
+	negativeDelay := valid
+	negativeDelay.TCPSocket = &TCPSocketProbe{Port: 80}
+	negativeDelay.InitialDelaySeconds = -1
+
+	negativePeriod := valid
+	negativePeriod.TCPSocket = &TCPSocketProbe{Port: 80}
+	negativePeriod.PeriodSeconds = -1
+
+	for _, h := range []Healthcheck{valid, both, badPath, negativeDelay, negativePeriod} {
+		if err := h.Validate(); !errors.Is(err, ErrInvalidHealthcheck) {
+			t.Errorf("%v: Expected %v, Got %v", h, ErrInvalidHealthcheck, err)
+		}
+	}
+
+	ok := valid
+	ok.Exec = &ExecProbe{Command: []string{"true"}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("Expected a valid healthcheck, Got %v", err)
+	}
+
+	unset := Healthcheck{TCPSocket: &TCPSocketProbe{Port: 80}}
+	if err := (Healthchecks{LivenessProbe: &unset}).Validate(); err != nil {
+		t.Errorf("Expected zero settings to be unset, Got %v", err)
+	}
+
+	readiness := ok
+	readiness.SuccessThreshold = 2
+	if err := (Healthchecks{ReadinessProbe: &readiness, LivenessProbe: &ok}).Validate(); err != nil {
+		t.Errorf("Expected valid healthchecks, Got %v", err)
+	}
+
+	if err := (Healthchecks{LivenessProbe: &readiness}).Validate(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v for a liveness success threshold above 1, Got %v", ErrInvalidHealthcheck, err)
+	}
+
+	if err := (Healthchecks{"startupProbe": &ok}).Validate(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v for an unknown probe type, Got %v", ErrInvalidHealthcheck, err)
+	}
+}
+
+func TestConfigSetHealthcheck(t *testing.T) {
+	t.Parallel()
+
+	h, err := NewTCPSocketHealthcheck(5000).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{}
+	if err := c.SetHealthcheck("web", LivenessProbe, h); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetHealthcheck("web", ReadinessProbe, h); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*c.Healthcheck["web"]) != 2 {
+		t.Errorf("Expected liveness and readiness probes, Got %v", c.Healthcheck["web"])
+	}
+
+	if err := c.SetHealthcheck("web", "typo", h); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidHealthcheck, err)
+	}
+
+	if err := c.ValidateHealthchecks(); err != nil {
+		t.Errorf("Expected valid healthchecks, Got %v", err)
+	}
+
+	c.Healthcheck["worker"] = &Healthchecks{LivenessProbe: &Healthcheck{TCPSocket: &TCPSocketProbe{Port: 80}, FailureThreshold: -1}}
+	if err := c.ValidateHealthchecks(); !errors.Is(err, ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidHealthcheck, err)
+	}
+}
+
+func TestParseHealthcheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input    string
+		Expected *Healthcheck
+	}{
+		{
+			"httpGet /health 5000 --headers X=Y",
+			unvalidated(NewHTTPGetHealthcheck("/health", 5000).Header("X", "Y")),
+		},
+		{
+			"httpGet 8080 --period=5 --headers 'X-A: 1,X-B=2' --initial-delay-timeout 0",
+			unvalidated(NewHTTPGetHealthcheck("/", 8080).Header("X-A", "1").Header("X-B", "2").Period(5).InitialDelay(0)),
+		},
+		{
+			"tcpSocket 6379 --timeout 2 --failure-threshold 5",
+			unvalidated(NewTCPSocketHealthcheck(6379).Timeout(2).FailureThreshold(5)),
+		},
+		{
+			`exec --period-seconds 30 /bin/sh -c "test -f /tmp/ready --quiet"`,
+			unvalidated(NewExecHealthcheck("/bin/sh", "-c", "test -f /tmp/ready --quiet").Period(30)),
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseHealthcheck(test.Input)
+		if err != nil {
+			t.Errorf("%s: %v", test.Input, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.Expected, actual) {
+			t.Errorf("%s: Expected %v, Got %v", test.Input, test.Expected, actual)
+		}
+	}
+
+	for _, input := range []string{
+		"",
+		"grpc 5000",
+		"httpGet",
+		"httpGet /health",
+		"httpGet /health 5000 extra",
+		"httpGet /health 0",
+		"httpGet /health 5000 --headers novalue",
+		"httpGet /health 5000 --period",
+		"httpGet /health 5000 --period soon",
+		"httpGet /health 5000 --unknown 1",
+		"tcpSocket 5000 --headers X=Y",
+		"tcpSocket",
+		"exec",
+		"exec 'unterminated",
+	} {
+		if _, err := ParseHealthcheck(input); !errors.Is(err, ErrInvalidHealthcheck) {
+			t.Errorf("%q: Expected %v, Got %v", input, ErrInvalidHealthcheck, err)
+		}
+	}
+}
+
+// unvalidated returns the healthcheck built so far without validating it.
+func unvalidated(b *HealthcheckBuilder) *Healthcheck {
+	h := b.h
+	return &h
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is the registry of images whose name has no registry host, such as redis.
+const DefaultRegistry = "docker.io"
+
+// DefaultTag is the tag pulled for images without a tag or digest.
+const DefaultTag = "latest"
+
+// officialRepositoryPrefix is the namespace of Docker Hub images with a single path component.
+const officialRepositoryPrefix = "library/"
+
+// maxImageNameLength is the maximum length of a registry host and repository path together.
+const maxImageNameLength = 255
+
+// ErrInvalidImageReference is returned when an image reference is malformed.
+var ErrInvalidImageReference = errors.New("invalid image reference")
+
+// The grammar of image references, from the docker distribution project.
+var (
+	domainRegex    = regexp.MustCompile(`^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(\.([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*|\[[a-fA-F0-9:]+\])(:[0-9]+)?$`)
+	pathComponent  = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*$`)
+	tagRegex       = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+	digestRegex    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*([-_+.][A-Za-z][A-Za-z0-9]*)*:[a-fA-F0-9]{32,}$`)
+	hexOnlyRegex   = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	digestSizes    = map[string]int{"sha256": 64, "sha384": 96, "sha512": 128}
+)
+
+// ImageReference is a container image reference, such as
+// registry.example.com:5000/team/app:v1.2@sha256:<hex>.
+type ImageReference struct {
+	// Domain is the registry host, with its port if any. It is empty for short names such as
+	// redis, which refer to DefaultRegistry.
+	Domain string
+	// Path is the repository path within the registry, such as team/app.
+	Path string
+	// Tag is the image tag, if any.
+	Tag string
+	// Digest is the content digest, such as sha256:<hex>, if any.
+	Digest string
+}
+
+// ParseImageReference parses an image reference following the docker distribution rules:
+// an optional registry host, a lowercase repository path of components separated by slashes,
+// an optional :tag and an optional @digest. The reference is not normalized, see Normalize.
+func ParseImageReference(s string) (ImageReference, error) {
+	if s == "" {
+		return ImageReference{}, fmt.Errorf("%w: reference is empty", ErrInvalidImageReference)
+	}
+
+	ref := ImageReference{}
+	name := s
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest, name = name[i+1:], name[:i]
+		if err := validateDigest(ref.Digest); err != nil {
+			return ImageReference{}, fmt.Errorf("%w: %q: %v", ErrInvalidImageReference, s, err)
+		}
+	}
+
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		ref.Tag, name = name[i+1:], name[:i]
+		if !tagRegex.MatchString(ref.Tag) {
+			return ImageReference{}, fmt.Errorf("%w: %q: invalid tag %q", ErrInvalidImageReference, s, ref.Tag)
+		}
+	}
+
+	if name == "" {
+		return ImageReference{}, fmt.Errorf("%w: %q: repository name is empty", ErrInvalidImageReference, s)
+	}
+	if len(name) > maxImageNameLength {
+		return ImageReference{}, fmt.Errorf("%w: %q: repository name must not be longer than %d characters",
+			ErrInvalidImageReference, s, maxImageNameLength)
+	}
+
+	ref.Path = name
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first {
+			ref.Domain, ref.Path = first, name[i+1:]
+			if !domainRegex.MatchString(ref.Domain) {
+				return ImageReference{}, fmt.Errorf("%w: %q: invalid registry host %q", ErrInvalidImageReference, s, ref.Domain)
+			}
+		}
+	}
+
+	for _, component := range strings.Split(ref.Path, "/") {
+		if pathComponent.MatchString(component) {
+			continue
+		}
+		if pathComponent.MatchString(strings.ToLower(component)) {
+			return ImageReference{}, fmt.Errorf("%w: %q: repository name must be lowercase", ErrInvalidImageReference, s)
+		}
+		return ImageReference{}, fmt.Errorf("%w: %q: invalid repository path component %q", ErrInvalidImageReference, s, component)
+	}
+
+	if ref.Domain == "" && hexOnlyRegex.MatchString(ref.Path) {
+		return ImageReference{}, fmt.Errorf("%w: %q: repository name cannot be a 64 character hexadecimal string",
+			ErrInvalidImageReference, s)
+	}
+
+	return ref, nil
+}
+
+// ParseNormalizedImageReference parses an image reference and normalizes it, see Normalize.
+func ParseNormalizedImageReference(s string) (ImageReference, error) {
+	ref, err := ParseImageReference(s)
+	if err != nil {
+		return ImageReference{}, err
+	}
+	return ref.Normalize(), nil
+}
+
+// Normalize returns the fully qualified form of a reference, as docker pulls it: short names
+// are on DefaultRegistry, official images get the library/ namespace, and references without
+// a tag or digest get DefaultTag. For example, redis becomes docker.io/library/redis:latest.
+func (r ImageReference) Normalize() ImageReference {
+	switch r.Domain {
+	case "", "index.docker.io", "registry-1.docker.io":
+		r.Domain = DefaultRegistry
+	}
+	if r.Domain == DefaultRegistry && !strings.Contains(r.Path, "/") {
+		r.Path = officialRepositoryPrefix + r.Path
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+	return r
+}
+
+// Registry returns the registry host of the image, DefaultRegistry for short names.
+func (r ImageReference) Registry() string {
+	if r.Domain == "" {
+		return DefaultRegistry
+	}
+	return r.Domain
+}
+
+// Name returns the registry host and repository path, without tag or digest.
+func (r ImageReference) Name() string {
+	if r.Domain == "" {
+		return r.Path
+	}
+	return r.Domain + "/" + r.Path
+}
+
+// String returns the reference as accepted by docker and the controller.
+func (r ImageReference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+func validateDigest(d string) error {
+	if !digestRegex.MatchString(d) {
+		return fmt.Errorf("invalid digest %q", d)
+	}
+	algorithm, hex, _ := strings.Cut(d, ":")
+	if size, ok := digestSizes[algorithm]; ok && len(hex) != size {
+		return fmt.Errorf("%s digest must be %d hexadecimal characters", algorithm, size)
+	}
+	return nil
+}
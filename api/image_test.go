@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	tests := []struct {
+		Input      string
+		Expected   ImageReference
+		Normalized string
+	}{
+		{"redis", ImageReference{Path: "redis"}, "docker.io/library/redis:latest"},
+		{"deis/example-go:v1", ImageReference{Path: "deis/example-go", Tag: "v1"}, "docker.io/deis/example-go:v1"},
+		{"index.docker.io/redis@" + digest, ImageReference{Domain: "index.docker.io", Path: "redis", Digest: digest},
+			"docker.io/library/redis@" + digest},
+		{"quay.io/team/sub/app:1.0-rc_1@" + digest, ImageReference{Domain: "quay.io", Path: "team/sub/app", Tag: "1.0-rc_1", Digest: digest},
+			"quay.io/team/sub/app:1.0-rc_1@" + digest},
+		{"localhost:5000/app", ImageReference{Domain: "localhost:5000", Path: "app"}, "localhost:5000/app:latest"},
+		{"localhost/app", ImageReference{Domain: "localhost", Path: "app"}, "localhost/app:latest"},
+		{"Registry/app", ImageReference{Domain: "Registry", Path: "app"}, "Registry/app:latest"},
+		{"[::1]:5000/app", ImageReference{Domain: "[::1]:5000", Path: "app"}, "[::1]:5000/app:latest"},
+		{"my.registry/a__b/c--d/e.f", ImageReference{Domain: "my.registry", Path: "a__b/c--d/e.f"}, "my.registry/a__b/c--d/e.f:latest"},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseImageReference(test.Input)
+		if err != nil {
+			t.Errorf("%s: %v", test.Input, err)
+			continue
+		}
+		if actual != test.Expected {
+			t.Errorf("%s: Expected %#v, Got %#v", test.Input, test.Expected, actual)
+		}
+		if actual.String() != test.Input {
+			t.Errorf("%s: Expected the reference to round trip, Got %s", test.Input, actual)
+		}
+		normalized, err := ParseNormalizedImageReference(test.Input)
+		if err != nil || normalized.String() != test.Normalized {
+			t.Errorf("%s: Expected %s, Got %s %v", test.Input, test.Normalized, normalized, err)
+		}
+	}
+
+	if ref, _ := ParseImageReference("redis"); ref.Registry() != DefaultRegistry || ref.Name() != "redis" {
+		t.Errorf("Expected redis on %s, Got %s %s", DefaultRegistry, ref.Registry(), ref.Name())
+	}
+}
+
+func TestParseImageReferenceErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input   string
+		Message string
+	}{
+		{"", "empty"},
+		{"deis/Example-Go", "must be lowercase"},
+		{"deis/example-go:", "invalid tag"},
+		{"deis/example-go:-v1", "invalid tag"},
+		{"deis/example-go:" + strings.Repeat("a", 129), "invalid tag"},
+		{"deis//example-go", "invalid repository path component"},
+		{"deis/example_-go", "invalid repository path component"},
+		{"deis/example-go@sha256:abc", "invalid digest"},
+		{"deis/example-go@sha256:" + strings.Repeat("a", 63), "must be 64"},
+		{"-registry.io/app", "invalid registry host"},
+		{"registry.io:port/app", "invalid registry host"},
+		{"registry.io/", "invalid repository path component"},
+		{":v1", "empty"},
+		{strings.Repeat("a", 64), "hexadecimal"},
+		{"a/" + strings.Repeat("b", 255), "longer than"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseImageReference(test.Input)
+		if !errors.Is(err, ErrInvalidImageReference) {
+			t.Errorf("%q: Expected %v, Got %v", test.Input, ErrInvalidImageReference, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.Message) {
+			t.Errorf("%q: Expected an error containing %q, Got %v", test.Input, test.Message, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+// MaskedConfig is a Config that masks sensitive values when it is printed or encoded to JSON,
+// for use in logs, debug output and exports. Values are classified by secrets.Default and
+// registry credentials are always masked. It must not be sent to the controller.
+type MaskedConfig Config
+
+// Masked returns a copy of the config with the values that classifier considers sensitive and
+// all registry credentials replaced by secrets.Mask. If classifier is nil, secrets.Default is used.
+func (c Config) Masked(classifier *secrets.Classifier) Config {
+	c.Values = classifier.MaskValues(c.Values)
+	c.Registry = secrets.MaskAll(c.Registry)
+	return c
+}
+
+// String displays the config as indented JSON, with sensitive values masked.
+func (c Config) String() string {
+	return MaskedConfig(c).String()
+}
+
+// String displays the config as indented JSON, with sensitive values masked.
+func (m MaskedConfig) String() string {
+	b, err := json.MarshalIndent(Config(m).Masked(nil), "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// MarshalJSON encodes the config with sensitive values masked.
+func (m MaskedConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Config(m).Masked(nil))
+}
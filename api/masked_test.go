@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMaskedConfig(t *testing.T) {
+	t.Parallel()
+
+	c := Config{
+		App:      "example-go",
+		Values:   map[string]interface{}{"DB_HOST": "db", "DB_PASSWORD": "hunter2"},
+		Registry: map[string]interface{}{"bob": "s3cret"},
+	}
+
+	for _, s := range []string{c.String(), MaskedConfig(c).String()} {
+		if strings.Contains(s, "hunter2") || strings.Contains(s, "s3cret") {
+			t.Errorf("Expected secrets to be masked, Got %s", s)
+		}
+		if !strings.Contains(s, `"DB_HOST": "db"`) {
+			t.Errorf("Expected other values to be shown, Got %s", s)
+		}
+	}
+
+	b, err := json.Marshal(MaskedConfig(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"app":"example-go","values":{"DB_HOST":"db","DB_PASSWORD":"********"},"registry":{"bob":"********"}}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, Got %s", expected, b)
+	}
+
+	// The config itself is still encoded in full, as it is sent to the controller.
+	b, err = json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "hunter2") {
+		t.Errorf("Expected the config to be encoded unmasked, Got %s", b)
+	}
+	if c.Values["DB_PASSWORD"] != "hunter2" {
+		t.Error("Expected the config not to be modified")
+	}
+}
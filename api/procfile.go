@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidProcessType is returned when a process type name is not accepted by the controller.
+var ErrInvalidProcessType = errors.New("invalid process type")
+
+// processTypeRegex matches the process type names accepted by the controller: lowercase
+// letters and digits, optionally separated by single hyphens.
+var processTypeRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ProcfileSyntaxError is returned when a Procfile cannot be parsed.
+type ProcfileSyntaxError struct {
+	Line int
+	Msg  string
+	// Err is ErrInvalidProcessType for invalid process type names, and nil otherwise.
+	Err error
+}
+
+func (e ProcfileSyntaxError) Error() string {
+	return fmt.Sprintf("Procfile syntax error on line %d: %s", e.Line, e.Msg)
+}
+
+// Unwrap returns the underlying error, so that errors.Is(err, ErrInvalidProcessType) works.
+func (e ProcfileSyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// ProcfileEntry is a process type and its command.
+type ProcfileEntry struct {
+	Type    string
+	Command string
+}
+
+// Procfile is an ordered list of process types, as written in a Procfile.
+type Procfile []ProcfileEntry
+
+// ValidateProcessType returns ErrInvalidProcessType if the name is not a valid process type.
+func ValidateProcessType(name string) error {
+	if !processTypeRegex.MatchString(name) {
+		return fmt.Errorf("%w: %q can only contain lowercase letters, digits and single hyphens", ErrInvalidProcessType, name)
+	}
+	return nil
+}
+
+// ParseProcfile parses a Procfile, where each line is a process type, a colon and a command:
+//
+//    web: bin/server --port $PORT
+//    worker: bin/worker
+//
+// Blank lines and lines starting with # are ignored. Process types must be valid for the
+// controller, see ValidateProcessType, and may only appear once. Entries keep their order.
+func ParseProcfile(r io.Reader) (Procfile, error) {
+	var p Procfile
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, ProcfileSyntaxError{Line: line, Msg: fmt.Sprintf("expected <process type>: <command>, got %q", text)}
+		}
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+
+		if err := ValidateProcessType(name); err != nil {
+			return nil, ProcfileSyntaxError{Line: line, Msg: err.Error(), Err: ErrInvalidProcessType}
+		}
+		if first, ok := seen[name]; ok {
+			return nil, ProcfileSyntaxError{Line: line, Msg: fmt.Sprintf("duplicate process type %q, first defined on line %d", name, first)}
+		}
+		if command == "" {
+			return nil, ProcfileSyntaxError{Line: line, Msg: fmt.Sprintf("process type %q has no command", name)}
+		}
+
+		seen[name] = line
+		p = append(p, ProcfileEntry{Type: name, Command: command})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewProcfile returns the Procfile of a process type map, in alphabetical order of process type.
+func NewProcfile(types ProcessType) Procfile {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p := make(Procfile, 0, len(names))
+	for _, name := range names {
+		p = append(p, ProcfileEntry{Type: name, Command: types[name]})
+	}
+	return p
+}
+
+// ProcessType returns the process types as a map, as taken by builds.New and hooks.CreateBuild.
+func (p Procfile) ProcessType() ProcessType {
+	types := make(ProcessType, len(p))
+	for _, e := range p {
+		types[e.Type] = e.Command
+	}
+	return types
+}
+
+// Types returns the process types in order.
+func (p Procfile) Types() []string {
+	types := make([]string, len(p))
+	for i, e := range p {
+		types[i] = e.Type
+	}
+	return types
+}
+
+// Validate checks that every process type is valid and unique and has a command.
+func (p Procfile) Validate() error {
+	seen := make(map[string]bool, len(p))
+	for _, e := range p {
+		if err := ValidateProcessType(e.Type); err != nil {
+			return err
+		}
+		if seen[e.Type] {
+			return fmt.Errorf("%w: %q is defined more than once", ErrInvalidProcessType, e.Type)
+		}
+		if strings.TrimSpace(e.Command) == "" || strings.ContainsAny(e.Command, "\r\n") {
+			return fmt.Errorf("%w: %q must have a single line command", ErrInvalidProcessType, e.Type)
+		}
+		seen[e.Type] = true
+	}
+	return nil
+}
+
+// String serializes the Procfile, one "type: command" line per process type.
+func (p Procfile) String() string {
+	var b strings.Builder
+	for _, e := range p {
+		b.WriteString(e.Type)
+		b.WriteString(": ")
+		b.WriteString(e.Command)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Validate checks that every process type name is valid and has a command.
+func (t ProcessType) Validate() error {
+	return NewProcfile(t).Validate()
+}
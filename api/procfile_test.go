@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProcfile(t *testing.T) {
+	t.Parallel()
+
+	input := "# processes\r\nweb: bin/server --port $PORT # not a comment\r\n\n  worker:bin/worker\nclock-2: bin/clock: tick\n"
+
+	actual, err := ParseProcfile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Procfile{
+		{Type: "web", Command: "bin/server --port $PORT # not a comment"},
+		{Type: "worker", Command: "bin/worker"},
+		{Type: "clock-2", Command: "bin/clock: tick"},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	if types := actual.Types(); !reflect.DeepEqual([]string{"web", "worker", "clock-2"}, types) {
+		t.Errorf("Expected the order to be kept, Got %v", types)
+	}
+
+	expectedTypes := ProcessType{"web": "bin/server --port $PORT # not a comment", "worker": "bin/worker", "clock-2": "bin/clock: tick"}
+	if types := actual.ProcessType(); !reflect.DeepEqual(expectedTypes, types) {
+		t.Errorf("Expected %v, Got %v", expectedTypes, types)
+	}
+
+	serialized := actual.String()
+	if serialized != "web: bin/server --port $PORT # not a comment\nworker: bin/worker\nclock-2: bin/clock: tick\n" {
+		t.Errorf("Unexpected Procfile %q", serialized)
+	}
+	if roundTrip, err := ParseProcfile(strings.NewReader(serialized)); err != nil || !reflect.DeepEqual(actual, roundTrip) {
+		t.Errorf("Expected %v, Got %v %v", actual, roundTrip, err)
+	}
+}
+
+func TestParseProcfileErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input   string
+		Line    int
+		Invalid bool
+	}{
+		{"web bin/server", 1, false},
+		{"web: bin/server\n\nweb: bin/other", 3, false},
+		{"# comment\nworker:", 2, false},
+		{"Web: bin/server", 1, true},
+		{"web_1: bin/server", 1, true},
+		{"web--1: bin/server", 1, true},
+		{"-web: bin/server", 1, true},
+		{": bin/server", 1, true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProcfile(strings.NewReader(test.Input))
+		var syntaxErr ProcfileSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: Expected a syntax error, Got %v", test.Input, err)
+			continue
+		}
+		if syntaxErr.Line != test.Line {
+			t.Errorf("%q: Expected line %d, Got %d", test.Input, test.Line, syntaxErr.Line)
+		}
+		if errors.Is(err, ErrInvalidProcessType) != test.Invalid {
+			t.Errorf("%q: Expected invalid process type %v, Got %v", test.Input, test.Invalid, err)
+		}
+	}
+}
+
+func TestProcessTypeValidate(t *testing.T) {
+	t.Parallel()
+
+	if err := (ProcessType{"web": "bin/server", "worker-2": "bin/worker"}).Validate(); err != nil {
+		t.Errorf("Expected valid process types, Got %v", err)
+	}
+
+	for _, types := range []ProcessType{
+		{"Web": "bin/server"},
+		{"web": " "},
+		{"web": "bin/server\nrm -rf /"},
+	} {
+		if err := types.Validate(); !errors.Is(err, ErrInvalidProcessType) {
+			t.Errorf("%v: Expected %v, Got %v", types, ErrInvalidProcessType, err)
+		}
+	}
+
+	p := Procfile{{Type: "web", Command: "a"}, {Type: "web", Command: "b"}}
+	if err := p.Validate(); !errors.Is(err, ErrInvalidProcessType) {
+		t.Errorf("Expected %v for a duplicate, Got %v", ErrInvalidProcessType, err)
+	}
+
+	if expected := (Procfile{{Type: "a", Command: "1"}, {Type: "b", Command: "2"}}); !reflect.DeepEqual(expected, NewProcfile(ProcessType{"b": "2", "a": "1"})) {
+		t.Errorf("Expected %v in alphabetical order", expected)
+	}
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidMemory is returned when a memory quantity cannot be parsed.
+	ErrInvalidMemory = errors.New("invalid memory quantity")
+	// ErrInvalidCPU is returned when a CPU quantity cannot be parsed.
+	ErrInvalidCPU = errors.New("invalid CPU quantity")
+	// ErrRequestExceedsLimit is returned when a resource request is greater than its limit.
+	ErrRequestExceedsLimit = errors.New("request must not be greater than limit")
+)
+
+var memoryRegex = regexp.MustCompile(`^([0-9]+)(B|K|M|G|KB|MB|GB)?$`)
+var cpuRegex = regexp.MustCompile(`^([0-9]+)(\.[0-9]+)?(m?)$`)
+
+// Memory units understood by the controller. They are binary: 1K is 1024 bytes.
+const (
+	Byte     Memory = 1
+	Kilobyte        = 1024 * Byte
+	Megabyte        = 1024 * Kilobyte
+	Gigabyte        = 1024 * Megabyte
+)
+
+var memoryUnits = []struct {
+	suffix string
+	size   Memory
+}{
+	{"G", Gigabyte},
+	{"M", Megabyte},
+	{"K", Kilobyte},
+	{"B", Byte},
+}
+
+// Memory is a quantity of memory in bytes.
+type Memory int64
+
+// ParseMemory parses a memory quantity in the controller's format: a whole number followed by
+// a unit, B, K, M or G (optionally followed by B, as in MB). Units are case insensitive and
+// binary, so 1K is 1024 bytes. Zero may be given without a unit.
+func ParseMemory(s string) (Memory, error) {
+	m := memoryRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMemory, s)
+	}
+
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMemory, s)
+	}
+
+	if m[2] == "" {
+		if n != 0 {
+			return 0, fmt.Errorf("%w: %q is missing a unit (B, K, M or G)", ErrInvalidMemory, s)
+		}
+		return 0, nil
+	}
+
+	for _, u := range memoryUnits {
+		if strings.HasPrefix(m[2], u.suffix) {
+			if n > int64(^uint64(0)>>1)/int64(u.size) {
+				return 0, fmt.Errorf("%w: %q is too large", ErrInvalidMemory, s)
+			}
+			return Memory(n) * u.size, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrInvalidMemory, s)
+}
+
+// String formats the quantity with the largest unit that represents it exactly, as in 512M.
+func (m Memory) String() string {
+	if m == 0 {
+		return "0"
+	}
+	for _, u := range memoryUnits {
+		if m%u.size == 0 {
+			return strconv.FormatInt(int64(m/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(m), 10) + "B"
+}
+
+// CPU is a quantity of CPU in thousandths of a CPU (millicores).
+type CPU int64
+
+// Millicore is a thousandth of a CPU, and Core is a whole CPU.
+const (
+	Millicore CPU = 1
+	Core          = 1000 * Millicore
+)
+
+// ParseCPU parses a CPU quantity in the controller's format: either a number of whole CPUs,
+// such as 1 or 0.5, or a whole number of millicores followed by m, such as 500m. A decimal
+// point must have a digit on each side. Quantities finer than one millicore are rejected.
+func ParseCPU(s string) (CPU, error) {
+	m := cpuRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCPU, s)
+	}
+
+	whole, frac, milli := m[1], strings.TrimPrefix(m[2], "."), m[3] == "m"
+	if milli && m[2] != "" {
+		return 0, fmt.Errorf("%w: %q must be a whole number of millicores", ErrInvalidCPU, s)
+	}
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCPU, s)
+	}
+	if milli {
+		return CPU(n), nil
+	}
+
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 3 {
+		return 0, fmt.Errorf("%w: %q is finer than one millicore", ErrInvalidCPU, s)
+	}
+	f, _ := strconv.ParseInt((frac + "000")[:3], 10, 64)
+
+	if n > int64(^uint64(0)>>1)/int64(Core) {
+		return 0, fmt.Errorf("%w: %q is too large", ErrInvalidCPU, s)
+	}
+	return CPU(n)*Core + CPU(f), nil
+}
+
+// String formats the quantity as whole CPUs if possible, otherwise in millicores, as in 500m.
+func (c CPU) String() string {
+	if c%Core == 0 {
+		return strconv.FormatInt(int64(c/Core), 10)
+	}
+	return strconv.FormatInt(int64(c), 10) + "m"
+}
+
+// MemoryLimit is the memory request and limit of a process type, written as a single value
+// (200M) or a request/limit pair (100M/200M). A single value sets both the request and the limit.
+type MemoryLimit struct {
+	Request Memory
+	Limit   Memory
+}
+
+// ParseMemoryLimit parses a memory limit, see MemoryLimit and ParseMemory.
+// It returns ErrRequestExceedsLimit if the request is greater than the limit.
+func ParseMemoryLimit(s string) (MemoryLimit, error) {
+	req, limit, pair := splitLimit(s)
+
+	l, err := ParseMemory(limit)
+	if err != nil {
+		return MemoryLimit{}, err
+	}
+	if !pair {
+		return MemoryLimit{Request: l, Limit: l}, nil
+	}
+
+	r, err := ParseMemory(req)
+	if err != nil {
+		return MemoryLimit{}, err
+	}
+
+	ml := MemoryLimit{Request: r, Limit: l}
+	return ml, ml.Validate()
+}
+
+// Validate returns ErrRequestExceedsLimit if the request is greater than the limit.
+func (m MemoryLimit) Validate() error {
+	if m.Request < 0 || m.Limit < 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidMemory, m)
+	}
+	if m.Request > m.Limit {
+		return fmt.Errorf("%w: %s", ErrRequestExceedsLimit, m)
+	}
+	return nil
+}
+
+// String formats the limit as accepted by the controller.
+func (m MemoryLimit) String() string {
+	if m.Request == m.Limit {
+		return m.Limit.String()
+	}
+	return m.Request.String() + "/" + m.Limit.String()
+}
+
+// CPULimit is the CPU request and limit of a process type, written as a single value
+// (500m) or a request/limit pair (250m/1). A single value sets both the request and the limit.
+type CPULimit struct {
+	Request CPU
+	Limit   CPU
+}
+
+// ParseCPULimit parses a CPU limit, see CPULimit and ParseCPU.
+// It returns ErrRequestExceedsLimit if the request is greater than the limit.
+func ParseCPULimit(s string) (CPULimit, error) {
+	req, limit, pair := splitLimit(s)
+
+	l, err := ParseCPU(limit)
+	if err != nil {
+		return CPULimit{}, err
+	}
+	if !pair {
+		return CPULimit{Request: l, Limit: l}, nil
+	}
+
+	r, err := ParseCPU(req)
+	if err != nil {
+		return CPULimit{}, err
+	}
+
+	cl := CPULimit{Request: r, Limit: l}
+	return cl, cl.Validate()
+}
+
+// Validate returns ErrRequestExceedsLimit if the request is greater than the limit.
+func (c CPULimit) Validate() error {
+	if c.Request < 0 || c.Limit < 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidCPU, c)
+	}
+	if c.Request > c.Limit {
+		return fmt.Errorf("%w: %s", ErrRequestExceedsLimit, c)
+	}
+	return nil
+}
+
+// String formats the limit as accepted by the controller.
+func (c CPULimit) String() string {
+	if c.Request == c.Limit {
+		return c.Limit.String()
+	}
+	return c.Request.String() + "/" + c.Limit.String()
+}
+
+func splitLimit(s string) (string, string, bool) {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return "", s, false
+}
+
+// MemoryLimit returns the memory limit of a process type. The second value is false if the
+// process type has no memory limit.
+func (c Config) MemoryLimit(procType string) (MemoryLimit, bool, error) {
+	v, ok := c.Memory[procType]
+	if !ok || v == nil {
+		return MemoryLimit{}, false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return MemoryLimit{}, true, fmt.Errorf("%w: %v", ErrInvalidMemory, v)
+	}
+	l, err := ParseMemoryLimit(s)
+	return l, true, err
+}
+
+// SetMemoryLimit sets the memory limit of a process type after validating it.
+func (c *Config) SetMemoryLimit(procType string, limit MemoryLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	if c.Memory == nil {
+		c.Memory = make(map[string]interface{})
+	}
+	c.Memory[procType] = limit.String()
+	return nil
+}
+
+// CPULimit returns the CPU limit of a process type. The second value is false if the
+// process type has no CPU limit.
+func (c Config) CPULimit(procType string) (CPULimit, bool, error) {
+	v, ok := c.CPU[procType]
+	if !ok || v == nil {
+		return CPULimit{}, false, nil
+	}
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case float64:
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return CPULimit{}, true, fmt.Errorf("%w: %v", ErrInvalidCPU, v)
+	}
+	l, err := ParseCPULimit(s)
+	return l, true, err
+}
+
+// SetCPULimit sets the CPU limit of a process type after validating it.
+func (c *Config) SetCPULimit(procType string, limit CPULimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	if c.CPU == nil {
+		c.CPU = make(map[string]interface{})
+	}
+	c.CPU[procType] = limit.String()
+	return nil
+}
+
+// ValidateLimits checks that every memory and CPU limit in the config can be parsed and that
+// no request is greater than its limit. Limits set to nil, which unset them, are ignored.
+// If several limits are invalid, the error is about the first one in alphabetical order of
+// process type, memory limits first.
+func (c Config) ValidateLimits() error {
+	for _, procType := range sortedKeys(c.Memory) {
+		if _, _, err := c.MemoryLimit(procType); err != nil {
+			return fmt.Errorf("memory limit for %s: %w", procType, err)
+		}
+	}
+	for _, procType := range sortedKeys(c.CPU) {
+		if _, _, err := c.CPULimit(procType); err != nil {
+			return fmt.Errorf("cpu limit for %s: %w", procType, err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMemory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input    string
+		Expected Memory
+		String   string
+	}{
+		{"0", 0, "0"},
+		{"512B", 512, "512B"},
+		{"2048B", 2 * Kilobyte, "2K"},
+		{"1k", Kilobyte, "1K"},
+		{"200M", 200 * Megabyte, "200M"},
+		{"200MB", 200 * Megabyte, "200M"},
+		{"1024M", Gigabyte, "1G"},
+		{"2G", 2 * Gigabyte, "2G"},
+		{"3gb", 3 * Gigabyte, "3G"},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseMemory(test.Input)
+		if err != nil {
+			t.Errorf("%s: %v", test.Input, err)
+			continue
+		}
+		if actual != test.Expected {
+			t.Errorf("%s: Expected %d, Got %d", test.Input, test.Expected, actual)
+		}
+		if actual.String() != test.String {
+			t.Errorf("%s: Expected %s, Got %s", test.Input, test.String, actual)
+		}
+	}
+
+	for _, input := range []string{"", "200", "1.5G", "-1M", "1T", "M", "99999999999999999G"} {
+		if _, err := ParseMemory(input); !errors.Is(err, ErrInvalidMemory) {
+			t.Errorf("%q: Expected %v, Got %v", input, ErrInvalidMemory, err)
+		}
+	}
+}
+
+func TestParseCPU(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input    string
+		Expected CPU
+		String   string
+	}{
+		{"0", 0, "0"},
+		{"1", Core, "1"},
+		{"2.5", 2500, "2500m"},
+		{"0.5", 500, "500m"},
+		{"0.25", 250, "250m"},
+		{"500m", 500, "500m"},
+		{"1000m", Core, "1"},
+		{"1.500", 1500, "1500m"},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseCPU(test.Input)
+		if err != nil {
+			t.Errorf("%s: %v", test.Input, err)
+			continue
+		}
+		if actual != test.Expected {
+			t.Errorf("%s: Expected %d, Got %d", test.Input, test.Expected, actual)
+		}
+		if actual.String() != test.String {
+			t.Errorf("%s: Expected %s, Got %s", test.Input, test.String, actual)
+		}
+	}
+
+	for _, input := range []string{"", ".", "m", ".m", "1.", ".25", "1.m", "-1", "0.0001", "1.5m", "1G", "abc"} {
+		if _, err := ParseCPU(input); !errors.Is(err, ErrInvalidCPU) {
+			t.Errorf("%q: Expected %v, Got %v", input, ErrInvalidCPU, err)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	t.Parallel()
+
+	mem, err := ParseMemoryLimit("100M/200M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.Request != 100*Megabyte || mem.Limit != 200*Megabyte || mem.String() != "100M/200M" {
+		t.Errorf("Expected 100M/200M, Got %v", mem)
+	}
+
+	mem, err = ParseMemoryLimit("1G")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.Request != Gigabyte || mem.Limit != Gigabyte || mem.String() != "1G" {
+		t.Errorf("Expected 1G, Got %v", mem)
+	}
+
+	if _, err := ParseMemoryLimit("200M/100M"); !errors.Is(err, ErrRequestExceedsLimit) {
+		t.Errorf("Expected %v, Got %v", ErrRequestExceedsLimit, err)
+	}
+
+	cpu, err := ParseCPULimit("250m/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Request != 250 || cpu.Limit != Core || cpu.String() != "250m/1" {
+		t.Errorf("Expected 250m/1, Got %v", cpu)
+	}
+
+	if _, err := ParseCPULimit("2/1"); !errors.Is(err, ErrRequestExceedsLimit) {
+		t.Errorf("Expected %v, Got %v", ErrRequestExceedsLimit, err)
+	}
+
+	if _, err := ParseCPULimit("1/x"); !errors.Is(err, ErrInvalidCPU) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidCPU, err)
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	t.Parallel()
+
+	c := Config{}
+
+	if err := c.SetMemoryLimit("web", MemoryLimit{Request: 128 * Megabyte, Limit: 256 * Megabyte}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCPULimit("web", CPULimit{Request: 500, Limit: 500}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetCPULimit("worker", CPULimit{Request: 2 * Core, Limit: Core}); !errors.Is(err, ErrRequestExceedsLimit) {
+		t.Errorf("Expected %v, Got %v", ErrRequestExceedsLimit, err)
+	}
+
+	if c.Memory["web"] != "128M/256M" || c.CPU["web"] != "500m" {
+		t.Errorf("Unexpected limits: memory %v, cpu %v", c.Memory, c.CPU)
+	}
+	if _, ok := c.CPU["worker"]; ok {
+		t.Error("Expected an invalid limit not to be set")
+	}
+
+	mem, ok, err := c.MemoryLimit("web")
+	if err != nil || !ok || mem.Limit != 256*Megabyte {
+		t.Errorf("Expected 256M limit, Got %v %v %v", mem, ok, err)
+	}
+
+	if _, ok, err := c.CPULimit("worker"); ok || err != nil {
+		t.Errorf("Expected no limit, Got %v %v", ok, err)
+	}
+
+	c.CPU["worker"] = 1.5
+	if cpu, _, err := c.CPULimit("worker"); err != nil || cpu.Limit != 1500 {
+		t.Errorf("Expected 1500m, Got %v %v", cpu, err)
+	}
+
+	c.Memory["worker"] = nil
+	if err := c.ValidateLimits(); err != nil {
+		t.Errorf("Expected valid limits, Got %v", err)
+	}
+
+	c.Memory["worker"] = "lots"
+	if err := c.ValidateLimits(); !errors.Is(err, ErrInvalidMemory) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidMemory, err)
+	}
+}
@@ -36,6 +36,20 @@ func List(c *deis.Client, results int) (api.Apps, int, error) {
 	return apps, count, reqErr
 }
 
+// ListAll lists all apps, fetching every page.
+func ListAll(c *deis.Client) (api.Apps, error) {
+	body, reqErr := c.LimitedRequestAll("/v2/apps/")
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	var apps api.Apps
+	if err := json.Unmarshal([]byte(body), &apps); err != nil {
+		return nil, err
+	}
+	return apps, reqErr
+}
+
 // New creates a new app with the given appID. Passing an empty string will result in
 // a randomized app name.
 //
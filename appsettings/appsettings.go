@@ -58,3 +58,24 @@ func Set(c *deis.Client, app string, appSettings api.AppSettings) (api.AppSettin
 
 	return newAppSettings, reqErr
 }
+
+// MergeFunc is called by SetIfUnchanged when an app's settings were modified since the caller
+// read them. It receives the app's current settings and the caller's patch, and returns the
+// patch to retry with. Returning an error aborts the update with that error.
+type MergeFunc func(current api.AppSettings, patch api.AppSettings) (api.AppSettings, error)
+
+// SetIfUnchanged sets an app's settings only if they have not been modified since the caller
+// read them, as identified by the settings' UUID, see deis.CompareAndSet. It guards changes
+// such as toggling maintenance mode against a concurrent change of the same settings, which
+// returns a deis.ErrModified unless merge rebases the patch onto the current settings.
+func SetIfUnchanged(c *deis.Client, app string, uuid string, patch api.AppSettings, merge MergeFunc,
+	retries int) (api.AppSettings, error) {
+	read := func() (api.AppSettings, string, error) {
+		current, err := List(c, app)
+		return current, current.UUID, err
+	}
+	set := func(patch api.AppSettings) (api.AppSettings, error) {
+		return Set(c, app, patch)
+	}
+	return deis.CompareAndSet("settings", uuid, patch, read, set, merge, retries)
+}
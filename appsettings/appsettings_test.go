@@ -310,3 +310,57 @@ func TestAppSettingsInvalidJson(t *testing.T) {
 		t.Errorf("Expected %v, Got %v", expected, err)
 	}
 }
+
+func TestAppSettingsSetIfUnchanged(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appSettingsVars := api.AppSettings{
+		Maintenance: &trueVar,
+		Routable:    api.NewRoutable(),
+		Whitelist:   []string{"1.2.3.4", "0.0.0.0/0"},
+		Autoscale: map[string]*api.Autoscale{
+			"cmd": {
+				Min:        3,
+				Max:        8,
+				CPUPercent: 40,
+			},
+		},
+		Label: map[string]interface{}{
+			"git_repo": "https://github.com/trilogy-group/devgraph-eyk-controller-sdk-go",
+			"team":     "deis",
+		},
+	}
+	currentUUID := "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+
+	if _, err := SetIfUnchanged(client, "example-go", currentUUID, appSettingsVars, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = SetIfUnchanged(client, "example-go", "stale", appSettingsVars, nil, 3)
+	expectedErr := deis.ErrModified{Resource: "settings", Expected: "stale", Actual: currentUUID}
+	if err != expectedErr {
+		t.Errorf("Expected %v, Got %v", expectedErr, err)
+	}
+
+	merges := 0
+	merge := func(current api.AppSettings, patch api.AppSettings) (api.AppSettings, error) {
+		merges++
+		return patch, nil
+	}
+
+	if _, err = SetIfUnchanged(client, "example-go", "stale", appSettingsVars, merge, 2); err != nil {
+		t.Fatal(err)
+	}
+	if merges != 1 {
+		t.Errorf("Expected 1 merge, Got %d", merges)
+	}
+}
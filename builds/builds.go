@@ -26,36 +26,62 @@ func List(c *deis.Client, appID string, results int) ([]api.Build, int, error) {
 	return builds, count, reqErr
 }
 
+// Get retrieves a build of an app by its UUID, such as the build of a release.
+func Get(c *deis.Client, appID string, uuid string) (api.Build, error) {
+	u := fmt.Sprintf("/v2/apps/%s/builds/%s/", appID, uuid)
+
+	res, reqErr := c.Request("GET", u, nil)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return api.Build{}, reqErr
+	}
+	defer res.Body.Close()
+
+	build := api.Build{}
+	if err := json.NewDecoder(res.Body).Decode(&build); err != nil {
+		return api.Build{}, err
+	}
+
+	return build, reqErr
+}
+
 // New creates a build for an app from an docker image.
 // By default this will create a cmd process that runs the CMD command from the Dockerfile.
 // If you want to define more process types, you can pass a Procfile map,
 // where the key is the process name and the value is the command for that process.
 // To pull from a private docker registry, a custom username and password must be set in the app's
 // configuration object. This can be done with `deis registry:set` or by using this SDK.
+// The image reference is checked with ValidateImage and the process types with
+// api.ProcessType.Validate before the request is sent. Use api.ParseProcfile to read a Procfile.
 //
-// This example adds custom registry credentials to an app:
+// This example adds the registry credentials from docker login to an app, then deploys an image:
 //    import (
-//    	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
-//    	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+//    	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/builds"
 //    )
 //
-//    // Create username/password map
-//    registryMap := map[string]string{
-//    	"username": "password"
-//    }
+//    image := "registry.example.com/team/app:v1"
 //
-//    // Create a new configuration, assign the credentials, and set it.
+//    // Read ~/.docker/config.json and set the credentials for registry.example.com,
+//    // running its docker credential helper if it has one.
 //    // Note that config setting is a patching operation, it doesn't overwrite or unset
 //    // unrelated configuration.
-//    newConfig := api.Config{}
-//    newConfig.Registry = registryMap
-//    _, err := config.Set(<client>, "appname", newConfig)
-//    if err != nil {
+//    if _, err := builds.SetRegistry(<client>, "appname", image, "", true); err != nil {
+//        log.Fatal(err)
+//    }
+//
+//    if _, err := builds.New(<client>, "appname", image, nil); err != nil {
 //        log.Fatal(err)
 //    }
 func New(c *deis.Client, appID string, image string,
 	procfile map[string]string) (api.Build, error) {
 
+	if err := ValidateImage(image); err != nil {
+		return api.Build{}, err
+	}
+
+	if err := api.ProcessType(procfile).Validate(); err != nil {
+		return api.Build{}, err
+	}
+
 	u := fmt.Sprintf("/v2/apps/%s/builds/", appID)
 
 	req := api.CreateBuildRequest{Image: image, Procfile: procfile}
@@ -1,6 +1,7 @@
 package builds
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -61,6 +62,11 @@ func (fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if req.URL.Path == "/v2/apps/example-go/builds/de1bf5b5-4a72-4f94-a10c-d2a3741cdf75/" && req.Method == "GET" {
+		res.Write([]byte(buildFixture))
+		return
+	}
+
 	if req.URL.Path == "/v2/apps/example-go/builds/" && req.Method == "POST" {
 		body, err := ioutil.ReadAll(req.Body)
 
@@ -126,6 +132,28 @@ func TestBuildsList(t *testing.T) {
 	}
 }
 
+func TestBuildGet(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := Get(deis, "example-go", "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Image != "deis/example-go:latest" || actual.Procfile["web"] != "example-go" {
+		t.Errorf("Unexpected build %v", actual)
+	}
+}
+
 func TestBuildCreate(t *testing.T) {
 	t.Parallel()
 
@@ -164,3 +192,59 @@ func TestBuildCreate(t *testing.T) {
 		t.Error(fmt.Errorf("Expected %v, Got %v", expected, actual))
 	}
 }
+
+func TestBuildCreateInvalidImage(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(deis, "example-go", "Deis/Example-Go", nil); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidImage, err)
+	}
+}
+
+func TestBuildCreateInvalidImageReference(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Images are parsed with api.ParseImageReference, whose errors also match ErrInvalidImage.
+	for _, image := range []string{"deis/example-go@sha256:short", "registry.example.com:port/app"} {
+		_, err := New(deis, "example-go", image, nil)
+		if !errors.Is(err, ErrInvalidImage) || !errors.Is(err, api.ErrInvalidImageReference) {
+			t.Errorf("%q: Expected %v, Got %v", image, api.ErrInvalidImageReference, err)
+		}
+	}
+}
+
+func TestBuildCreateInvalidProcfile(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	procfile := map[string]string{"Web_1": "example-go"}
+	if _, err := New(deis, "example-go", "deis/example-go", procfile); !errors.Is(err, api.ErrInvalidProcessType) {
+		t.Errorf("Expected %v, Got %v", api.ErrInvalidProcessType, err)
+	}
+}
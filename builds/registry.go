@@ -0,0 +1,299 @@
+package builds
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+)
+
+// DockerHub is the registry host of images without one, such as deis/example-go.
+const DockerHub = api.DefaultRegistry
+
+// dockerHubServer is the server URL under which docker stores Docker Hub credentials.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+var (
+	// ErrInvalidImage is returned when an image reference is malformed. It is the error the
+	// controller returns for invalid images, so both can be handled the same way; the precise
+	// cause matches api.ErrInvalidImageReference.
+	ErrInvalidImage = deis.ErrInvalidImage
+	// ErrNoCredentials is returned when the docker config has no credentials for a registry.
+	ErrNoCredentials = errors.New("no registry credentials")
+)
+
+// imageError is a malformed image reference error that matches deis.ErrInvalidImage and
+// unwraps to its api.ErrInvalidImageReference cause.
+type imageError struct {
+	err error
+}
+
+func (e imageError) Error() string {
+	return e.err.Error()
+}
+
+func (e imageError) Unwrap() error {
+	return e.err
+}
+
+func (e imageError) Is(target error) bool {
+	return target == deis.ErrInvalidImage
+}
+
+// ParseImage parses an image reference, see api.ParseImageReference.
+// Errors match both ErrInvalidImage and api.ErrInvalidImageReference.
+func ParseImage(image string) (api.ImageReference, error) {
+	ref, err := api.ParseImageReference(image)
+	if err != nil {
+		return api.ImageReference{}, imageError{err}
+	}
+	return ref, nil
+}
+
+// ValidateImage checks that an image reference is well-formed, as in
+// registry.example.com:5000/team/app:v1.2 or deis/example-go@sha256:<hex>.
+func ValidateImage(image string) error {
+	_, err := ParseImage(image)
+	return err
+}
+
+// ImageHost returns the registry host of an image reference, with its port if any.
+// Images without a registry, such as deis/example-go, are on DockerHub.
+func ImageHost(image string) (string, error) {
+	ref, err := ParseImage(image)
+	if err != nil {
+		return "", err
+	}
+	return registryHost(ref.Registry()), nil
+}
+
+// DockerConfig is the part of docker's config.json that holds registry credentials.
+type DockerConfig struct {
+	// Auths are the credentials stored in the file, by registry server.
+	Auths map[string]DockerAuth `json:"auths"`
+	// CredsStore is the credential helper used for registries without a CredHelpers entry,
+	// such as "osxkeychain" for docker-credential-osxkeychain.
+	CredsStore string `json:"credsStore,omitempty"`
+	// CredHelpers are the credential helpers of specific registries.
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+	// UseCredentialHelpers allows Credentials to run the docker-credential-* programs named
+	// by CredsStore and CredHelpers. It is off by default, so only the credentials stored in
+	// the file are used unless the caller opts in.
+	UseCredentialHelpers bool `json:"-"`
+}
+
+// DockerAuth is a registry entry of a docker config. Auth is the base64 encoding of
+// username:password, as written by docker login; Username and Password are used if it is empty.
+type DockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// credentialHelper runs a docker credential helper for a registry server and returns its
+// JSON output. It is replaced in tests.
+var credentialHelper = func(helper string, server string) ([]byte, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker-credential-%s: %v: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// DockerConfigPath returns the path of the current user's docker config, in the directory
+// set by $DOCKER_CONFIG or ~/.docker.
+func DockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".docker", "config.json")
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// LoadDockerConfig reads a docker config. If path is empty, DockerConfigPath is used.
+func LoadDockerConfig(path string) (DockerConfig, error) {
+	if path == "" {
+		path = DockerConfigPath()
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return DockerConfig{}, err
+	}
+
+	dc := DockerConfig{}
+	if err := json.Unmarshal(b, &dc); err != nil {
+		return DockerConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return dc, nil
+}
+
+// Credentials returns the username and password for a registry host, as returned by
+// ImageHost. Like docker, it uses the registry's credential helper if there is one, then the
+// credentials stored in the file, then the default credential store. Credential helpers are
+// only run if UseCredentialHelpers is set.
+//
+// If several servers of the file normalize to the host, such as https://index.docker.io/v1/
+// and docker.io, the server docker itself writes for the host is used first, then the others
+// in alphabetical order.
+// It returns ErrNoCredentials if none of them has credentials for the host.
+func (dc DockerConfig) Credentials(host string) (string, string, error) {
+	host = registryHost(host)
+
+	if dc.UseCredentialHelpers {
+		servers := make([]string, 0, len(dc.CredHelpers))
+		for server := range dc.CredHelpers {
+			servers = append(servers, server)
+		}
+		if matching := matchingServers(servers, host); len(matching) > 0 {
+			return helperCredentials(dc.CredHelpers[matching[0]], matching[0])
+		}
+	}
+
+	servers := make([]string, 0, len(dc.Auths))
+	for server := range dc.Auths {
+		servers = append(servers, server)
+	}
+	for _, server := range matchingServers(servers, host) {
+		auth := dc.Auths[server]
+		if auth.Auth == "" && auth.Username == "" {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("auth for %s is not base64 encoded: %w", server, err)
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return "", "", fmt.Errorf("auth for %s is not of the form username:password", server)
+		}
+		return username, password, nil
+	}
+
+	if dc.CredsStore != "" && dc.UseCredentialHelpers {
+		return helperCredentials(dc.CredsStore, dockerServer(host))
+	}
+
+	return "", "", fmt.Errorf("%w: for %s", ErrNoCredentials, host)
+}
+
+// RegistryConfig returns the config that sets the credentials of an image's registry,
+// taken from a docker config.
+func RegistryConfig(image string, dc DockerConfig) (api.Config, error) {
+	host, err := ImageHost(image)
+	if err != nil {
+		return api.Config{}, err
+	}
+
+	username, password, err := dc.Credentials(host)
+	if err != nil {
+		return api.Config{}, err
+	}
+
+	return api.Config{Registry: map[string]interface{}{"username": username, "password": password}}, nil
+}
+
+// SetRegistry sets an app's registry credentials for a private image from the docker config
+// at dockerConfigPath, or the current user's docker config if it is empty. Credential helpers
+// are only run if useCredentialHelpers is true, see DockerConfig.Credentials.
+// Call it before New to deploy images from a registry you are logged in to with docker login.
+func SetRegistry(c *deis.Client, appID string, image string, dockerConfigPath string,
+	useCredentialHelpers bool) (api.Config, error) {
+	dc, err := LoadDockerConfig(dockerConfigPath)
+	if err != nil {
+		return api.Config{}, err
+	}
+	dc.UseCredentialHelpers = useCredentialHelpers
+
+	registry, err := RegistryConfig(image, dc)
+	if err != nil {
+		return api.Config{}, err
+	}
+
+	return config.Set(c, appID, registry)
+}
+
+func helperCredentials(helper string, server string) (string, string, error) {
+	out, err := credentialHelper(helper, server)
+	if err != nil {
+		return "", "", err
+	}
+
+	creds := struct {
+		Username string
+		Secret   string
+	}{}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return "", "", fmt.Errorf("docker-credential-%s: %w", helper, err)
+	}
+	if creds.Username == "" || creds.Username == "<token>" {
+		return "", "", fmt.Errorf("%w: docker-credential-%s has no username and password for %s",
+			ErrNoCredentials, helper, server)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// matchingServers returns the servers of a docker config that normalize to host, the one
+// docker writes for host first and the others sorted.
+func matchingServers(servers []string, host string) []string {
+	var matching []string
+	for _, server := range servers {
+		if registryHost(server) == host {
+			matching = append(matching, server)
+		}
+	}
+	canonical := dockerServer(host)
+	sort.Slice(matching, func(i, j int) bool {
+		if (matching[i] == canonical) != (matching[j] == canonical) {
+			return matching[i] == canonical
+		}
+		return matching[i] < matching[j]
+	})
+	return matching
+}
+
+// dockerServer returns the server under which docker stores the credentials of a registry host.
+func dockerServer(host string) string {
+	if host == DockerHub {
+		return dockerHubServer
+	}
+	return host
+}
+
+// registryHost normalizes a docker config server, such as https://index.docker.io/v1/,
+// to a registry host as returned by ImageHost.
+func registryHost(server string) string {
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	server = strings.ToLower(server)
+	switch server {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DockerHub
+	}
+	return server
+}
@@ -0,0 +1,238 @@
+package builds
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+const registrySetExpected string = `{"registry":{"password":"hunter2","username":"bob"}}`
+
+type registryHTTPServer struct{}
+
+func (registryHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/example-go/config/" && req.Method == "POST" {
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			fmt.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+		}
+
+		if string(body) != registrySetExpected {
+			fmt.Printf("Expected '%s', Got '%s'\n", registrySetExpected, body)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(`{"app":"example-go","registry":{"username":"bob","password":"hunter2"}}`))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestImageHost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Image string
+		Host  string
+	}{
+		{"example-go", DockerHub},
+		{"deis/example-go:latest", DockerHub},
+		{"library/redis@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", DockerHub},
+		{"quay.io/deis/example-go:v1.2", "quay.io"},
+		{"localhost/app", "localhost"},
+		{"localhost:5000/team/app", "localhost:5000"},
+		{"registry.example.com:5000/team/sub/app:1.0-rc_1", "registry.example.com:5000"},
+		// Like docker, a first component with uppercase letters is a registry host.
+		{"Deis/example-go", "deis"},
+	}
+
+	for _, test := range tests {
+		actual, err := ImageHost(test.Image)
+		if err != nil {
+			t.Errorf("%s: %v", test.Image, err)
+			continue
+		}
+		if actual != test.Host {
+			t.Errorf("%s: Expected %s, Got %s", test.Image, test.Host, actual)
+		}
+	}
+
+	for _, image := range []string{
+		"",
+		"deis/Example-go",
+		"deis/example-go:",
+		"deis/example-go:-bad",
+		"deis//example-go",
+		"deis/example-go@sha256:short",
+		"-registry.example.com/app",
+		"registry.example.com:port/app",
+		"registry.example.com/",
+	} {
+		if err := ValidateImage(image); !errors.Is(err, ErrInvalidImage) || !errors.Is(err, api.ErrInvalidImageReference) {
+			t.Errorf("%q: Expected %v, Got %v", image, ErrInvalidImage, err)
+		}
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	helper := credentialHelper
+	defer func() { credentialHelper = helper }()
+
+	credentialHelper = func(name string, server string) ([]byte, error) {
+		switch {
+		case name == "ecr-login" && server == "123.dkr.ecr.us-east-1.amazonaws.com":
+			return []byte(`{"ServerURL":"123.dkr.ecr.us-east-1.amazonaws.com","Username":"AWS","Secret":"ecr"}`), nil
+		case name == "desktop" && server == dockerHubServer:
+			return []byte(`{"ServerURL":"https://index.docker.io/v1/","Username":"hubuser","Secret":"hub"}`), nil
+		case name == "desktop" && server == "gcr.io":
+			return []byte(`{"ServerURL":"gcr.io","Username":"<token>","Secret":"identity"}`), nil
+		}
+		return nil, fmt.Errorf("credentials not found in native keychain")
+	}
+
+	dc := DockerConfig{
+		Auths: map[string]DockerAuth{
+			"https://registry.example.com/v2/": {Auth: base64.StdEncoding.EncodeToString([]byte("bob:hun:ter2"))},
+			"quay.io":                          {Username: "alice", Password: "quay"},
+			"https://index.docker.io/v1/":      {},
+		},
+		CredsStore:           "desktop",
+		CredHelpers:          map[string]string{"123.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+		UseCredentialHelpers: true,
+	}
+
+	tests := []struct {
+		Host     string
+		Username string
+		Password string
+	}{
+		{"registry.example.com", "bob", "hun:ter2"},
+		{"Quay.io", "alice", "quay"},
+		{"123.dkr.ecr.us-east-1.amazonaws.com", "AWS", "ecr"},
+		{DockerHub, "hubuser", "hub"},
+	}
+
+	for _, test := range tests {
+		username, password, err := dc.Credentials(test.Host)
+		if err != nil {
+			t.Errorf("%s: %v", test.Host, err)
+			continue
+		}
+		if username != test.Username || password != test.Password {
+			t.Errorf("%s: Expected %s:%s, Got %s:%s", test.Host, test.Username, test.Password, username, password)
+		}
+	}
+
+	if _, _, err := dc.Credentials("gcr.io"); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected %v for an identity token, Got %v", ErrNoCredentials, err)
+	}
+	if _, _, err := dc.Credentials("ghcr.io"); err == nil {
+		t.Error("Expected an error from the credential store")
+	}
+
+	// Without the opt-in, helpers are never run.
+	dc.UseCredentialHelpers = false
+	for _, host := range []string{"123.dkr.ecr.us-east-1.amazonaws.com", DockerHub, "ghcr.io"} {
+		if _, _, err := dc.Credentials(host); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("%s: Expected %v, Got %v", host, ErrNoCredentials, err)
+		}
+	}
+	dc.UseCredentialHelpers = true
+
+	dc.CredsStore = ""
+	if _, _, err := dc.Credentials("ghcr.io"); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected %v, Got %v", ErrNoCredentials, err)
+	}
+
+	dc.Auths["ghcr.io"] = DockerAuth{Auth: "bm9jb2xvbg=="}
+	if _, _, err := dc.Credentials("ghcr.io"); err == nil {
+		t.Error("Expected an error for an auth without a colon")
+	}
+}
+
+func TestDockerConfigCredentialsPrecedence(t *testing.T) {
+	t.Parallel()
+
+	dc := DockerConfig{Auths: map[string]DockerAuth{
+		"docker.io":                   {Username: "alias", Password: "a"},
+		"https://index.docker.io/v1/": {Username: "hubuser", Password: "hub"},
+		"index.docker.io":             {Username: "index", Password: "i"},
+		"https://quay.io":             {Username: "url", Password: "u"},
+		"quay.io":                     {Username: "alice", Password: "quay"},
+		"quay.io/v1/":                 {Username: "path", Password: "p"},
+	}}
+
+	// Map order must not matter, so check repeatedly.
+	for i := 0; i < 20; i++ {
+		if username, _, err := dc.Credentials(DockerHub); err != nil || username != "hubuser" {
+			t.Fatalf("Expected hubuser, Got %s %v", username, err)
+		}
+		if username, _, err := dc.Credentials("quay.io"); err != nil || username != "alice" {
+			t.Fatalf("Expected alice, Got %s %v", username, err)
+		}
+	}
+
+	delete(dc.Auths, "quay.io")
+	if username, _, err := dc.Credentials("quay.io"); err != nil || username != "url" {
+		t.Errorf("Expected the first server in alphabetical order, Got %s %v", username, err)
+	}
+}
+
+func TestSetRegistry(t *testing.T) {
+	t.Parallel()
+
+	handler := registryHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	dockerConfig := `{"auths":{"registry.example.com":{"auth":"Ym9iOmh1bnRlcjI="}}}`
+	if err := ioutil.WriteFile(path, []byte(dockerConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := api.Config{App: "example-go", Registry: map[string]interface{}{"username": "bob", "password": "hunter2"}}
+	actual, err := SetRegistry(client, "example-go", "registry.example.com/team/app:v1", path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	if _, err := SetRegistry(client, "example-go", "quay.io/team/app", path, false); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected %v, Got %v", ErrNoCredentials, err)
+	}
+}
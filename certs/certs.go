@@ -25,10 +25,25 @@ func List(c *deis.Client, results int) ([]api.Cert, int, error) {
 	return res, count, reqErr
 }
 
+// ListAll lists all certificates added to deis, fetching every page.
+func ListAll(c *deis.Client) ([]api.Cert, error) {
+	body, reqErr := c.LimitedRequestAll("/v2/certs/")
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	var certs []api.Cert
+	if err := json.Unmarshal([]byte(body), &certs); err != nil {
+		return nil, err
+	}
+	return certs, reqErr
+}
+
 // New creates a new certificate.
 // Certificates are created independently from apps and are applied on a per domain basis.
 // So to enable SSL for an app with the domain test.com, you would first create the certificate,
 // then use the attach method to attach test.com to the certificate.
+// Use Validate to check the certificate and key before creating it.
 func New(c *deis.Client, cert string, key string, name string) (api.Cert, error) {
 	req := api.CertCreateRequest{Certificate: cert, Key: key, Name: name}
 	reqBody, err := json.Marshal(req)
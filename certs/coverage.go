@@ -0,0 +1,151 @@
+package certs
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/domains"
+)
+
+// CoverageStatus classifies a domain by the certificate attached to it.
+type CoverageStatus string
+
+const (
+	// Covered domains are attached to a certificate whose names match them.
+	Covered CoverageStatus = "covered"
+	// Uncovered domains are not attached to a certificate.
+	Uncovered CoverageStatus = "uncovered"
+	// Mismatched domains are attached to a certificate whose names do not match them.
+	Mismatched CoverageStatus = "mismatched"
+)
+
+// DomainCoverage is a domain in a coverage report.
+type DomainCoverage struct {
+	Domain string         `json:"domain"`
+	App    string         `json:"app"`
+	Status CoverageStatus `json:"status"`
+	// Cert is the certificate attached to the domain, if any.
+	Cert string `json:"cert,omitempty"`
+	// Candidates are the certificates whose names match the domain, if it is not covered.
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// CoverageReport matches the domains of apps against the certificates of the controller.
+type CoverageReport struct {
+	// Domains are ordered by app, then by domain.
+	Domains []DomainCoverage `json:"domains"`
+	// Unused are the names of the certificates attached to no domain, ordered by name.
+	Unused []string `json:"unused"`
+}
+
+// Filter returns the domains of the report that have one of the statuses.
+func (r CoverageReport) Filter(statuses ...CoverageStatus) []DomainCoverage {
+	domains := []DomainCoverage{}
+	for _, d := range r.Domains {
+		for _, status := range statuses {
+			if d.Status == status {
+				domains = append(domains, d)
+				break
+			}
+		}
+	}
+	return domains
+}
+
+// Uncovered returns the domains that are served without a matching certificate, that is
+// Uncovered and Mismatched domains.
+func (r CoverageReport) Uncovered() []DomainCoverage {
+	return r.Filter(Uncovered, Mismatched)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r CoverageReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// CheckCoverage matches the domains of apps, or of every app visible to the user if none is
+// given, against every certificate.
+func CheckCoverage(c *deis.Client, appIDs ...string) (CoverageReport, error) {
+	certs, err := ListAll(c)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return CoverageReport{}, err
+	}
+
+	appDomains, err := domains.ListApps(c, appIDs...)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return CoverageReport{}, err
+	}
+
+	return NewCoverageReport(certs, appDomains), nil
+}
+
+// NewCoverageReport matches domains against certificates. A domain is covered if the
+// certificate attached to it has a name that matches it, see MatchDomain and CertNames.
+func NewCoverageReport(certs []api.Cert, appDomains api.Domains) CoverageReport {
+	attached := map[string]api.Cert{}
+	r := CoverageReport{Domains: []DomainCoverage{}, Unused: []string{}}
+	for _, cert := range certs {
+		if len(cert.Domains) == 0 {
+			r.Unused = append(r.Unused, cert.Name)
+		}
+		for _, domain := range cert.Domains {
+			attached[domain] = cert
+		}
+	}
+
+	for _, d := range appDomains {
+		coverage := DomainCoverage{Domain: d.Domain, App: d.App, Status: Uncovered}
+		if cert, ok := attached[d.Domain]; ok {
+			coverage.Cert = cert.Name
+			coverage.Status = Mismatched
+			if covers(cert, d.Domain) {
+				coverage.Status = Covered
+			}
+		}
+		if coverage.Status != Covered {
+			for _, cert := range certs {
+				if covers(cert, d.Domain) {
+					coverage.Candidates = append(coverage.Candidates, cert.Name)
+				}
+			}
+			sort.Strings(coverage.Candidates)
+		}
+		r.Domains = append(r.Domains, coverage)
+	}
+
+	sort.SliceStable(r.Domains, func(i, j int) bool {
+		a, b := r.Domains[i], r.Domains[j]
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		return a.Domain < b.Domain
+	})
+	sort.Strings(r.Unused)
+	return r
+}
+
+// CertNames returns the names a certificate is valid for: its subject alternative names, or
+// its common name if it has none.
+func CertNames(cert api.Cert) []string {
+	if len(cert.SubjectAltName) > 0 {
+		return cert.SubjectAltName
+	}
+	if cert.CommonName != "" {
+		return []string{cert.CommonName}
+	}
+	return nil
+}
+
+func covers(cert api.Cert, domain string) bool {
+	for _, name := range CertNames(cert) {
+		if MatchDomain(name, domain) {
+			return true
+		}
+	}
+	return false
+}
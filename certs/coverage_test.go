@@ -0,0 +1,87 @@
+package certs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+)
+
+var coverageFixtures = map[string]string{
+	"/v2/certs/": `{"count": 3, "next": null, "previous": null, "results": [
+		{"name": "www-example-com", "common_name": "www.example.com", "san": ["www.example.com", "example.com"],
+		 "expires": "2021-01-01T00:00:00UTC", "domains": ["www.example.com", "example.com"]},
+		{"name": "wildcard-example-com", "common_name": "*.example.com", "expires": "2021-01-01T00:00:00UTC",
+		 "domains": ["a.b.example.com", "api.example.com", "*.example.com"]},
+		{"name": "old-example-com", "common_name": "old.example.com", "expires": "2020-05-01T00:00:00UTC"}
+	]}`,
+	"/v2/apps/": `{"count": 2, "next": null, "previous": null, "results": [{"id": "web"}, {"id": "api"}]}`,
+	"/v2/apps/web/domains/": `{"count": 4, "next": null, "previous": null, "results": [
+		{"app": "web", "domain": "www.example.com"}, {"app": "web", "domain": "example.com"},
+		{"app": "web", "domain": "shop.example.com"}, {"app": "web", "domain": "a.b.example.com"}]}`,
+	"/v2/apps/api/domains/": `{"count": 2, "next": null, "previous": null, "results": [
+		{"app": "api", "domain": "api.example.com"}, {"app": "api", "domain": "*.example.com"}]}`,
+}
+
+type coverageHTTPServer struct{}
+
+func (coverageHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if fixture, ok := coverageFixtures[req.URL.Path]; ok && req.Method == "GET" {
+		res.Write([]byte(fixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestCheckCoverage(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(coverageHTTPServer{})
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := CheckCoverage(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := CoverageReport{
+		Domains: []DomainCoverage{
+			{Domain: "*.example.com", App: "api", Status: Covered, Cert: "wildcard-example-com"},
+			{Domain: "api.example.com", App: "api", Status: Covered, Cert: "wildcard-example-com"},
+			{Domain: "a.b.example.com", App: "web", Status: Mismatched, Cert: "wildcard-example-com"},
+			{Domain: "example.com", App: "web", Status: Covered, Cert: "www-example-com"},
+			{Domain: "shop.example.com", App: "web", Status: Uncovered, Candidates: []string{"wildcard-example-com"}},
+			{Domain: "www.example.com", App: "web", Status: Covered, Cert: "www-example-com"},
+		},
+		Unused: []string{"old-example-com"},
+	}
+	if !reflect.DeepEqual(expected, r) {
+		t.Errorf("Expected %+v, Got %+v", expected, r)
+	}
+
+	uncovered := []DomainCoverage{expected.Domains[2], expected.Domains[4]}
+	if actual := r.Uncovered(); !reflect.DeepEqual(uncovered, actual) {
+		t.Errorf("Expected %+v, Got %+v", uncovered, actual)
+	}
+
+	r, err = CheckCoverage(client, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Domains) != 2 || len(r.Uncovered()) != 0 {
+		t.Errorf("Expected the domains of api to be covered, Got %+v", r.Domains)
+	}
+}
@@ -0,0 +1,257 @@
+package certs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/domains"
+)
+
+// ExpiryStatus classifies a certificate by the time left before it expires.
+type ExpiryStatus string
+
+const (
+	// Expired certificates are no longer valid.
+	Expired ExpiryStatus = "expired"
+	// Critical certificates expire within the critical threshold, 7 days by default.
+	Critical ExpiryStatus = "critical"
+	// Warning certificates expire within the warning threshold, 30 days by default.
+	Warning ExpiryStatus = "warning"
+	// Valid certificates expire after the warning threshold.
+	Valid ExpiryStatus = "valid"
+	// Unknown certificates have no expiry date.
+	Unknown ExpiryStatus = "unknown"
+)
+
+// Thresholds are the durations before expiry at which certificates become Critical or Warning.
+type Thresholds struct {
+	Critical time.Duration `json:"critical"`
+	Warning  time.Duration `json:"warning"`
+}
+
+// DefaultMonitorInterval is the interval between two checks of MonitorExpiry.
+const DefaultMonitorInterval = time.Hour
+
+// DefaultThresholds flags certificates that expire within 7 and 30 days.
+var DefaultThresholds = Thresholds{Critical: 7 * 24 * time.Hour, Warning: 30 * 24 * time.Hour}
+
+// Status classifies a certificate that expires at expires, at time now.
+func (t Thresholds) Status(expires time.Time, now time.Time) ExpiryStatus {
+	left := expires.Sub(now)
+	switch {
+	case expires.IsZero():
+		return Unknown
+	case left <= 0:
+		return Expired
+	case left < t.Critical:
+		return Critical
+	case left < t.Warning:
+		return Warning
+	}
+	return Valid
+}
+
+// Expiry is a certificate in an expiry report.
+type Expiry struct {
+	Name       string       `json:"name"`
+	CommonName string       `json:"common_name"`
+	Issuer     string       `json:"issuer"`
+	Expires    time.Time    `json:"expires"`
+	Status     ExpiryStatus `json:"status"`
+	// DaysLeft is the number of whole days before the certificate expires, rounded down, so
+	// it is negative as soon as the certificate has expired.
+	DaysLeft int `json:"days_left"`
+	// Domains are the domains the certificate is attached to.
+	Domains []string `json:"domains"`
+	// Apps are the apps of those domains, if they are visible to the user.
+	Apps []string `json:"apps"`
+}
+
+// ExpiryReport is the expiry status of every certificate of the controller.
+type ExpiryReport struct {
+	Generated  time.Time  `json:"generated"`
+	Thresholds Thresholds `json:"thresholds"`
+	// Certs are ordered by expiry date, soonest first, then by name. Certificates without an
+	// expiry date come last.
+	Certs []Expiry `json:"certs"`
+}
+
+// Filter returns the certificates of the report that have one of the statuses.
+func (r ExpiryReport) Filter(statuses ...ExpiryStatus) []Expiry {
+	certs := []Expiry{}
+	for _, cert := range r.Certs {
+		for _, status := range statuses {
+			if cert.Status == status {
+				certs = append(certs, cert)
+				break
+			}
+		}
+	}
+	return certs
+}
+
+// Expiring returns the certificates that are expired or within a threshold.
+func (r ExpiryReport) Expiring() []Expiry {
+	return r.Filter(Expired, Critical, Warning)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r ExpiryReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ExpiryOptions controls CheckExpiry.
+type ExpiryOptions struct {
+	// Thresholds defaults to DefaultThresholds.
+	Thresholds Thresholds
+	// Now is the time of the report. Defaults to the current time.
+	Now time.Time
+}
+
+// CheckExpiry lists every certificate and classifies it by expiry date. Attached domains are
+// mapped to apps through the domains of every app visible to the user.
+func CheckExpiry(c *deis.Client, opts ExpiryOptions) (ExpiryReport, error) {
+	certs, err := ListAll(c)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return ExpiryReport{}, err
+	}
+
+	appDomains, err := domains.ListApps(c)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return ExpiryReport{}, err
+	}
+
+	return NewExpiryReport(certs, appDomains, opts), nil
+}
+
+// NewExpiryReport classifies certificates by expiry date, and maps their domains to the apps
+// of appDomains.
+func NewExpiryReport(certs []api.Cert, appDomains api.Domains, opts ExpiryOptions) ExpiryReport {
+	if opts.Thresholds == (Thresholds{}) {
+		opts.Thresholds = DefaultThresholds
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	appOf := map[string]string{}
+	for _, d := range appDomains {
+		appOf[d.Domain] = d.App
+	}
+
+	r := ExpiryReport{Generated: opts.Now, Thresholds: opts.Thresholds, Certs: []Expiry{}}
+	for _, cert := range certs {
+		e := Expiry{Name: cert.Name, CommonName: cert.CommonName, Issuer: cert.Issuer, Domains: []string{},
+			Apps: []string{}}
+		if cert.Expires.Time != nil {
+			e.Expires = *cert.Expires.Time
+		}
+		e.Status = opts.Thresholds.Status(e.Expires, opts.Now)
+		if !e.Expires.IsZero() {
+			e.DaysLeft = int(math.Floor(e.Expires.Sub(opts.Now).Hours() / 24))
+		}
+
+		seen := map[string]bool{}
+		for _, domain := range cert.Domains {
+			e.Domains = append(e.Domains, domain)
+			if app, ok := appOf[domain]; ok && !seen[app] {
+				seen[app] = true
+				e.Apps = append(e.Apps, app)
+			}
+		}
+		sort.Strings(e.Domains)
+		sort.Strings(e.Apps)
+		r.Certs = append(r.Certs, e)
+	}
+
+	sort.SliceStable(r.Certs, func(i, j int) bool {
+		a, b := r.Certs[i], r.Certs[j]
+		if a.Expires.IsZero() != b.Expires.IsZero() {
+			return b.Expires.IsZero()
+		}
+		if !a.Expires.Equal(b.Expires) {
+			return a.Expires.Before(b.Expires)
+		}
+		return a.Name < b.Name
+	})
+	return r
+}
+
+// MonitorExpiry runs CheckExpiry every interval, starting now, and calls f with each report
+// until the context is done or f returns an error. Errors of CheckExpiry are passed to
+// onError if it is set, and otherwise stop the monitor. It returns the context's error, or
+// the error that stopped it. If interval is not positive, DefaultMonitorInterval is used.
+//
+// This example writes a report every hour and alerts on expiring certificates:
+//
+//    err := certs.MonitorExpiry(ctx, <client>, time.Hour, certs.ExpiryOptions{}, func(r certs.ExpiryReport) error {
+//    	for _, cert := range r.Expiring() {
+//    		alert(cert)
+//    	}
+//    	return certs.WriteExpiryReport("/var/lib/certs/expiry.json")(r)
+//    }, nil)
+func MonitorExpiry(ctx context.Context, c *deis.Client, interval time.Duration, opts ExpiryOptions,
+	f func(ExpiryReport) error, onError func(error)) error {
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r, err := CheckExpiry(c, opts)
+		if err == nil {
+			err = f(r)
+		} else if onError != nil {
+			onError(err)
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// The context may be done too, as select picks either case at random.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WriteExpiryReport returns a function that writes a report as JSON to path, replacing the
+// previous report atomically, for use as the MonitorExpiry callback.
+func WriteExpiryReport(path string) func(ExpiryReport) error {
+	return func(r ExpiryReport) error {
+		tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		if err := r.WriteJSON(tmp); err != nil {
+			tmp.Close()
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	}
+}
@@ -0,0 +1,192 @@
+package certs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	dtime "github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/time"
+)
+
+var expiryNow = time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+var expiryFixtures = map[string]string{
+	"/v2/certs/": `{"count": 4, "next": null, "previous": null, "results": [
+		{"name": "www-example-com", "common_name": "www.example.com", "expires": "2020-06-20T00:00:00UTC",
+		 "issuer": "/C=US/O=Let's Encrypt/CN=R3", "domains": ["www.example.com", "example.com"]},
+		{"name": "api-example-com", "common_name": "api.example.com", "expires": "2020-06-03T12:00:00UTC",
+		 "domains": ["api.example.com"]},
+		{"name": "old-example-com", "common_name": "old.example.com", "expires": "2020-05-01T00:00:00UTC"},
+		{"name": "new-example-com", "common_name": "new.example.com", "expires": "2021-01-01T00:00:00UTC",
+		 "domains": ["new.example.com"]}
+	]}`,
+	"/v2/apps/": `{"count": 2, "next": null, "previous": null, "results": [{"id": "web"}, {"id": "api"}]}`,
+	"/v2/apps/web/domains/": `{"count": 2, "next": null, "previous": null, "results": [
+		{"app": "web", "domain": "www.example.com"}, {"app": "web", "domain": "example.com"}]}`,
+	"/v2/apps/api/domains/": `{"count": 1, "next": null, "previous": null, "results": [
+		{"app": "api", "domain": "api.example.com"}]}`,
+}
+
+type expiryHTTPServer struct{}
+
+func (expiryHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if fixture, ok := expiryFixtures[req.URL.Path]; ok && req.Method == "GET" {
+		res.Write([]byte(fixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestCheckExpiry(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(expiryHTTPServer{})
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := CheckExpiry(client, ExpiryOptions{Now: expiryNow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Expiry{
+		{Name: "old-example-com", CommonName: "old.example.com", Expires: time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC),
+			Status: Expired, DaysLeft: -31, Domains: []string{}, Apps: []string{}},
+		{Name: "api-example-com", CommonName: "api.example.com", Expires: time.Date(2020, time.June, 3, 12, 0, 0, 0, time.UTC),
+			Status: Critical, DaysLeft: 2, Domains: []string{"api.example.com"}, Apps: []string{"api"}},
+		{Name: "www-example-com", CommonName: "www.example.com", Issuer: "/C=US/O=Let's Encrypt/CN=R3", Expires: time.Date(2020, time.June, 20, 0, 0, 0, 0, time.UTC),
+			Status: Warning, DaysLeft: 19, Domains: []string{"example.com", "www.example.com"}, Apps: []string{"web"}},
+		{Name: "new-example-com", CommonName: "new.example.com", Expires: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Status: Valid, DaysLeft: 214, Domains: []string{"new.example.com"}, Apps: []string{}},
+	}
+	for i := range r.Certs {
+		// The controller's time zone is parsed as a zone named UTC, not as time.UTC.
+		r.Certs[i].Expires = r.Certs[i].Expires.UTC()
+	}
+	if !reflect.DeepEqual(expected, r.Certs) {
+		t.Errorf("Expected %+v, Got %+v", expected, r.Certs)
+	}
+
+	if expiring := r.Expiring(); len(expiring) != 3 || len(r.Filter(Valid)) != 1 || r.Thresholds != DefaultThresholds {
+		t.Errorf("Unexpected report %+v", r)
+	}
+}
+
+func TestNewExpiryReportDaysLeft(t *testing.T) {
+	t.Parallel()
+
+	expires := func(d time.Duration) dtime.Time {
+		t := expiryNow.Add(d)
+		return dtime.Time{Time: &t}
+	}
+	certs := []api.Cert{
+		{Name: "just-expired", Expires: expires(-time.Minute)},
+		{Name: "expiring", Expires: expires(time.Minute)},
+		{Name: "expired-yesterday", Expires: expires(-25 * time.Hour)},
+	}
+
+	r := NewExpiryReport(certs, nil, ExpiryOptions{Now: expiryNow})
+	expected := map[string]int{"expired-yesterday": -2, "just-expired": -1, "expiring": 0}
+	for _, e := range r.Certs {
+		if e.DaysLeft != expected[e.Name] {
+			t.Errorf("%s: Expected %d days left, Got %d", e.Name, expected[e.Name], e.DaysLeft)
+		}
+	}
+}
+
+func TestMonitorExpiry(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(expiryHTTPServer{})
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "expiry.json")
+	write := WriteExpiryReport(path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checks := 0
+	err = MonitorExpiry(ctx, client, time.Millisecond, ExpiryOptions{Now: expiryNow}, func(r ExpiryReport) error {
+		checks++
+		if checks == 2 {
+			cancel()
+		}
+		return write(r)
+	}, nil)
+	if !errors.Is(err, context.Canceled) || checks != 2 {
+		t.Errorf("Expected 2 checks and %v, Got %d %v", context.Canceled, checks, err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ExpiryReport{}
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Certs) != 4 || r.Certs[0].Status != Expired || !r.Generated.Equal(expiryNow) {
+		t.Errorf("Unexpected written report %+v", r)
+	}
+
+	closed := httptest.NewServer(expiryHTTPServer{})
+	closed.Close()
+	failing, err := deis.New(false, closed.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noop := func(ExpiryReport) error { return nil }
+
+	if err := MonitorExpiry(context.Background(), failing, time.Millisecond, ExpiryOptions{}, noop, nil); err == nil {
+		t.Error("Expected the check error to stop the monitor")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	reported := 0
+	err = MonitorExpiry(ctx, failing, time.Millisecond, ExpiryOptions{}, noop, func(error) {
+		reported++
+		if reported == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) || reported != 2 {
+		t.Errorf("Expected 2 reported errors and %v, Got %d %v", context.Canceled, reported, err)
+	}
+
+	// A zero interval falls back to DefaultMonitorInterval instead of panicking.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = MonitorExpiry(ctx, client, 0, ExpiryOptions{}, func(ExpiryReport) error {
+		cancel()
+		return nil
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, Got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,166 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ParsePKCS12 builds a certificate request from a PKCS#12 archive, such as a .pfx or .p12
+// file, protected by password. The archive must hold the private key and its certificate, and
+// may hold the chain. Both legacy archives and archives encrypted with PBES2 and AES, as
+// written by OpenSSL 3 and recent Windows versions, are supported. If name is empty,
+// DefaultName of the certificate's common name is used.
+func ParsePKCS12(data []byte, password string, name string) (api.CertCreateRequest, error) {
+	privateKey, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return api.CertCreateRequest{}, invalid("pkcs12: %w", err)
+	}
+	if leaf == nil {
+		return api.CertCreateRequest{}, invalid("pkcs12: %w", ErrNoCertificate)
+	}
+	key, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return api.CertCreateRequest{}, invalid("pkcs12: %w", ErrNoPrivateKey)
+	}
+
+	return newRequest(append([]*x509.Certificate{leaf}, caCerts...), key, name)
+}
+
+// LoadPKCS12 builds a certificate request from a PKCS#12 file, see ParsePKCS12.
+func LoadPKCS12(path string, password string, name string) (api.CertCreateRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return api.CertCreateRequest{}, err
+	}
+	return ParsePKCS12(data, password, name)
+}
+
+// LoadFiles builds a certificate request from PEM files: the certificate, optionally its chain
+// of intermediates, and the private key. The certificates may be in any order. If name is
+// empty, DefaultName of the certificate's common name is used.
+func LoadFiles(certPath string, chainPath string, keyPath string, name string) (api.CertCreateRequest, error) {
+	paths := []string{certPath}
+	if chainPath != "" {
+		paths = append(paths, chainPath)
+	}
+
+	var chain []*x509.Certificate
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return api.CertCreateRequest{}, err
+		}
+		certs, err := ParseChain(data)
+		if err != nil {
+			return api.CertCreateRequest{}, fmt.Errorf("%s: %w", path, err)
+		}
+		chain = append(chain, certs...)
+	}
+
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return api.CertCreateRequest{}, err
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return api.CertCreateRequest{}, fmt.Errorf("%s: %w", keyPath, err)
+	}
+
+	return newRequest(chain, key, name)
+}
+
+// LoadCertbot builds a certificate request from the certificate certbot manages as
+// certName, in the live/<certName>/ directory of its config directory dir, such as
+// /etc/letsencrypt. If name is empty, certName is used.
+func LoadCertbot(dir string, certName string, name string) (api.CertCreateRequest, error) {
+	live := filepath.Join(dir, "live", certName)
+	if name == "" {
+		name = DefaultName(certName)
+	}
+
+	key := filepath.Join(live, "privkey.pem")
+	fullchain := filepath.Join(live, "fullchain.pem")
+	if _, err := os.Stat(fullchain); err == nil {
+		return LoadFiles(fullchain, "", key, name)
+	}
+	return LoadFiles(filepath.Join(live, "cert.pem"), filepath.Join(live, "chain.pem"), key, name)
+}
+
+// NormalizeKey converts a PEM private key in PKCS#1, PKCS#8 or SEC 1 format to the format
+// the controller loads: PKCS#1 for RSA keys and SEC 1 for EC keys. Other keys are PKCS#8.
+func NormalizeKey(keyPEM string) (string, error) {
+	key, err := ParsePrivateKey([]byte(keyPEM))
+	if err != nil {
+		return "", err
+	}
+	return encodeKey(key)
+}
+
+// DefaultName returns a certificate name derived from a domain, as the controller requires
+// them: lowercase letters, digits and hyphens. Wildcards become "wildcard", so the name of
+// *.example.com is wildcard-example-com.
+func DefaultName(domain string) string {
+	domain = strings.Replace(strings.ToLower(domain), "*", "wildcard", -1)
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, domain)
+	for strings.Contains(name, "--") {
+		name = strings.Replace(name, "--", "-", -1)
+	}
+	return strings.Trim(name, "-")
+}
+
+// newRequest orders a chain, checks that the key is the leaf's and encodes both.
+func newRequest(certs []*x509.Certificate, key crypto.Signer, name string) (api.CertCreateRequest, error) {
+	chain, err := OrderChain(certs)
+	if err != nil {
+		return api.CertCreateRequest{}, err
+	}
+	if !KeyMatches(chain[0], key) {
+		return api.CertCreateRequest{}, certError{ErrKeyMismatch}
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return api.CertCreateRequest{}, err
+	}
+	if name == "" {
+		name = DefaultName(chain[0].Subject.CommonName)
+	}
+	return api.CertCreateRequest{Certificate: EncodeChain(chain), Key: keyPEM, Name: name}, nil
+}
+
+func encodeKey(key crypto.Signer) (string, error) {
+	block := &pem.Block{}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		block.Type, block.Bytes = "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		block.Type, block.Bytes = "EC PRIVATE KEY", der
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		block.Type, block.Bytes = "PRIVATE KEY", der
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
@@ -0,0 +1,143 @@
+package certs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+// ErrUncoveredDomain is returned when a new certificate does not cover a domain the
+// certificate it replaces is attached to.
+var ErrUncoveredDomain = errors.New("certificate does not cover domain")
+
+// rotationSuffix matches the suffix Rotate adds to certificate names.
+var rotationSuffix = regexp.MustCompile(`-[0-9]{14}(-2)?$`)
+
+// RotateError is returned when a rotation fails after the new certificate was created. The
+// domains moved to the new certificate are moved back to the old one and the new certificate
+// is deleted; RollbackErrors are the steps of this rollback that failed, if any.
+type RotateError struct {
+	// Domain is the domain being moved when the rotation failed, empty if it failed later.
+	Domain         string
+	Err            error
+	RollbackErrors []error
+}
+
+func (e *RotateError) Error() string {
+	msg := e.Err.Error()
+	if e.Domain != "" {
+		msg = fmt.Sprintf("moving %s: %s", e.Domain, msg)
+	}
+	for _, err := range e.RollbackErrors {
+		msg += fmt.Sprintf("; rollback: %s", err)
+	}
+	return msg
+}
+
+func (e *RotateError) Unwrap() error {
+	return e.Err
+}
+
+// Rotate replaces a certificate with a new certificate and key without leaving its domains
+// uncovered. The new certificate is validated locally first and must cover every domain the
+// old one is attached to. It is then uploaded under a new name, the old name with the leaf's
+// issue time as suffix, such as www-example-com-20200601120000, and each domain is moved to it
+// one by one. If the old certificate already has that name, because it was issued at the same
+// time, "-2" is added to the new name. The old certificate is deleted only once every domain
+// is moved.
+//
+// If moving a domain fails, the domains already moved are moved back, the new certificate is
+// deleted and a *RotateError is returned. It returns the new certificate.
+func Rotate(c *deis.Client, oldName string, newPEM string, newKey string) (api.Cert, error) {
+	old, err := Get(c, oldName)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Cert{}, err
+	}
+
+	info, err := Validate(newPEM, newKey, ValidateOptions{})
+	if err != nil {
+		return api.Cert{}, err
+	}
+	var uncovered []string
+	for _, domain := range old.Domains {
+		if !info.Covers(domain) {
+			uncovered = append(uncovered, domain)
+		}
+	}
+	if len(uncovered) > 0 {
+		return api.Cert{}, invalid("%w: %s is valid for %s, not %s", ErrUncoveredDomain, describe(info.Leaf()),
+			strings.Join(info.Names(), ", "), strings.Join(uncovered, ", "))
+	}
+
+	key, err := NormalizeKey(newKey)
+	if err != nil {
+		return api.Cert{}, err
+	}
+	newName := rotationSuffix.ReplaceAllString(oldName, "") + "-" + info.NotBefore.UTC().Format("20060102150405")
+	if newName == oldName {
+		newName += "-2"
+	}
+	cert, err := New(c, info.PEM(), key, newName)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Cert{}, err
+	}
+
+	var moved []string
+	for _, domain := range old.Domains {
+		if err := move(c, domain, oldName, newName); err != nil {
+			rotateErr := &RotateError{Domain: domain, Err: err}
+			// The domain may have been detached from the old certificate before the failure.
+			if attachErr := Attach(c, oldName, domain); attachErr != nil && !alreadyAttached(c, oldName, domain) {
+				rotateErr.RollbackErrors = append(rotateErr.RollbackErrors, fmt.Errorf("attaching %s: %w", domain, attachErr))
+			}
+			for i := len(moved) - 1; i >= 0; i-- {
+				if moveErr := move(c, moved[i], newName, oldName); moveErr != nil {
+					rotateErr.RollbackErrors = append(rotateErr.RollbackErrors,
+						fmt.Errorf("moving %s back: %w", moved[i], moveErr))
+				}
+			}
+			if deleteErr := Delete(c, newName); deleteErr != nil {
+				rotateErr.RollbackErrors = append(rotateErr.RollbackErrors,
+					fmt.Errorf("deleting %s: %w", newName, deleteErr))
+			}
+			return api.Cert{}, rotateErr
+		}
+		moved = append(moved, domain)
+	}
+
+	cert.Domains = moved
+	if err := Delete(c, oldName); err != nil {
+		return cert, fmt.Errorf("every domain was moved to %s, but deleting %s failed: %w", newName, oldName, err)
+	}
+	return cert, nil
+}
+
+// move attaches a domain to another certificate. A domain has a single certificate, so
+// attaching it replaces the current one without leaving the domain uncovered. If the
+// controller rejects the attach, the domain is detached from its certificate first.
+func move(c *deis.Client, domain string, from string, to string) error {
+	if err := Attach(c, to, domain); err == nil {
+		return nil
+	}
+	if err := Detach(c, from, domain); err != nil {
+		return err
+	}
+	return Attach(c, to, domain)
+}
+
+func alreadyAttached(c *deis.Client, name string, domain string) bool {
+	cert, err := Get(c, name)
+	if err != nil {
+		return false
+	}
+	for _, d := range cert.Domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package certs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+// rotateHTTPServer keeps the domains attached to each certificate. A domain can only be
+// attached to one certificate at a time, attaching it to another one replaces it.
+type rotateHTTPServer struct {
+	mu    sync.Mutex
+	certs map[string][]string
+	// failAttach makes attaching this domain to a certificate other than the original fail.
+	failAttach string
+	// rejectAttached makes attaching a domain that already has a certificate fail.
+	rejectAttached bool
+	// detached are the domains that were left without a certificate.
+	detached []string
+}
+
+func (s *rotateHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/certs/")
+	name, domainPath, _ := strings.Cut(path, "/domain/")
+	name = strings.TrimSuffix(name, "/")
+	_, exists := s.certs[name]
+
+	switch {
+	case path == "" && req.Method == "POST":
+		body, _ := ioutil.ReadAll(req.Body)
+		cert := api.CertCreateRequest{}
+		json.Unmarshal(body, &cert)
+		s.certs[cert.Name] = []string{}
+		res.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(res, `{"name": %q}`, cert.Name)
+	case exists && !strings.Contains(path, "/domain/") && req.Method == "GET":
+		domains, _ := json.Marshal(s.certs[name])
+		fmt.Fprintf(res, `{"name": %q, "domains": %s}`, name, domains)
+	case exists && !strings.Contains(path, "/domain/") && req.Method == "DELETE":
+		delete(s.certs, name)
+		res.WriteHeader(http.StatusNoContent)
+	case exists && domainPath == "" && req.Method == "POST":
+		body, _ := ioutil.ReadAll(req.Body)
+		attach := api.CertAttachRequest{}
+		json.Unmarshal(body, &attach)
+		current := s.attachedTo(attach.Domain)
+		if (current != "" && s.rejectAttached) || (attach.Domain == s.failAttach && name != "www-example-com") {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte(`{"detail": "domain already has a certificate"}`))
+			return
+		}
+		if current != "" {
+			s.certs[current] = without(s.certs[current], attach.Domain)
+		}
+		s.certs[name] = append(s.certs[name], attach.Domain)
+		res.WriteHeader(http.StatusCreated)
+	case exists && domainPath != "" && req.Method == "DELETE" && s.attachedTo(domainPath) == name:
+		s.certs[name] = without(s.certs[name], domainPath)
+		s.detached = append(s.detached, domainPath)
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+	}
+}
+
+func (s *rotateHTTPServer) attachedTo(domain string) string {
+	for name, domains := range s.certs {
+		for _, d := range domains {
+			if d == domain {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func without(domains []string, domain string) []string {
+	rest := []string{}
+	for _, d := range domains {
+		if d != domain {
+			rest = append(rest, d)
+		}
+	}
+	return rest
+}
+
+func newRotateServer(t *testing.T) (*rotateHTTPServer, *deis.Client, func()) {
+	s := &rotateHTTPServer{certs: map[string][]string{"www-example-com": {"www.example.com", "example.com"}}}
+	server := httptest.NewServer(s)
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, client, server.Close
+}
+
+func (s *rotateHTTPServer) state() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := map[string][]string{}
+	for name, domains := range s.certs {
+		state[name] = append([]string{}, domains...)
+		sort.Strings(state[name])
+	}
+	return state
+}
+
+func (s *rotateHTTPServer) detachedDomains() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.detached...)
+}
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+
+	tc := newTestChain(t)
+	name := "www-example-com-" + tc.leaf.cert.NotBefore.UTC().Format("20060102150405")
+
+	cert, err := Rotate(client, "www-example-com", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Name != name || !reflect.DeepEqual([]string{"www.example.com", "example.com"}, cert.Domains) {
+		t.Errorf("Unexpected certificate %+v", cert)
+	}
+
+	expected := map[string][]string{name: {"example.com", "www.example.com"}}
+	if actual := s.state(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+	if detached := s.detachedDomains(); len(detached) != 0 {
+		t.Errorf("Expected no domain without a certificate, Got %v", detached)
+	}
+}
+
+func TestRotateRejectedAttach(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+	s.rejectAttached = true
+
+	tc := newTestChain(t)
+	name := "www-example-com-" + tc.leaf.cert.NotBefore.UTC().Format("20060102150405")
+
+	if _, err := Rotate(client, "www-example-com", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{name: {"example.com", "www.example.com"}}
+	if actual := s.state(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+	if detached := s.detachedDomains(); !reflect.DeepEqual([]string{"www.example.com", "example.com"}, detached) {
+		t.Errorf("Expected the domains to be detached first, Got %v", detached)
+	}
+}
+
+func TestRotateSameName(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+
+	tc := newTestChain(t)
+	name := "www-example-com-" + tc.leaf.cert.NotBefore.UTC().Format("20060102150405")
+	s.certs = map[string][]string{name: {"www.example.com", "example.com"}}
+
+	cert, err := Rotate(client, name, tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Name != name+"-2" {
+		t.Errorf("Expected %s-2, Got %s", name, cert.Name)
+	}
+
+	expected := map[string][]string{name + "-2": {"example.com", "www.example.com"}}
+	if actual := s.state(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	// Rotating again goes back to the name without "-2".
+	if cert, err = Rotate(client, name+"-2", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t)); err != nil {
+		t.Fatal(err)
+	}
+	if cert.Name != name {
+		t.Errorf("Expected %s, Got %s", name, cert.Name)
+	}
+}
+
+func TestRotateWildcardDomain(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+	s.certs = map[string][]string{"www-example-com": {"*.example.com"}}
+
+	tc := newTestChain(t, "*.example.com", "*.example.com")
+	cert, err := Rotate(client, "www-example-com", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{cert.Name: {"*.example.com"}}
+	if actual := s.state(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestRotateRollback(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+	s.failAttach = "example.com"
+
+	tc := newTestChain(t)
+	_, err := Rotate(client, "www-example-com", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t))
+
+	rotateErr := &RotateError{}
+	if !errors.As(err, &rotateErr) || rotateErr.Domain != "example.com" || len(rotateErr.RollbackErrors) != 0 {
+		t.Fatalf("Expected a rotate error for example.com, Got %v", err)
+	}
+
+	expected := map[string][]string{"www-example-com": {"example.com", "www.example.com"}}
+	if actual := s.state(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestRotateUncovered(t *testing.T) {
+	t.Parallel()
+
+	s, client, stop := newRotateServer(t)
+	defer stop()
+
+	tc := newTestChain(t, "www.example.com", "www.example.com")
+	_, err := Rotate(client, "www-example-com", tc.leaf.pem()+tc.intermediate.pem(), tc.leaf.keyPEM(t))
+	if !errors.Is(err, ErrUncoveredDomain) || !strings.HasSuffix(err.Error(), "not example.com") {
+		t.Errorf("Expected %v, Got %v", ErrUncoveredDomain, err)
+	}
+
+	if actual := s.state(); len(actual) != 1 {
+		t.Errorf("Expected no new certificate, Got %v", actual)
+	}
+}
@@ -0,0 +1,403 @@
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+)
+
+var (
+	// ErrNoCertificate is returned when a PEM string has no certificate.
+	ErrNoCertificate = errors.New("no certificate found in PEM data")
+	// ErrNoPrivateKey is returned when a PEM string has no private key.
+	ErrNoPrivateKey = errors.New("no private key found in PEM data")
+	// ErrKeyMismatch is returned when the private key is not the key of the certificate.
+	ErrKeyMismatch = errors.New("private key does not match the certificate")
+	// ErrNotYetValid is returned when a certificate of the chain is not valid yet.
+	ErrNotYetValid = errors.New("certificate is not valid yet")
+	// ErrExpired is returned when a certificate of the chain has expired.
+	ErrExpired = errors.New("certificate has expired")
+	// ErrInvalidChain is returned when the certificates of a chain do not issue each other.
+	ErrInvalidChain = errors.New("invalid certificate chain")
+	// ErrIncompleteChain is returned when a chain lacks intermediates found in the
+	// intermediates pool.
+	ErrIncompleteChain = errors.New("certificate chain is missing intermediates")
+	// ErrUntrustedChain is returned when a chain cannot be verified against the roots.
+	ErrUntrustedChain = errors.New("certificate chain is not trusted")
+)
+
+// certError is a certificate error that matches deis.ErrInvalidCertificate, the error the
+// controller returns for invalid certificates, and unwraps to its precise cause.
+type certError struct {
+	err error
+}
+
+func (e certError) Error() string {
+	return e.err.Error()
+}
+
+func (e certError) Unwrap() error {
+	return e.err
+}
+
+func (e certError) Is(target error) bool {
+	return target == deis.ErrInvalidCertificate
+}
+
+func invalid(format string, a ...interface{}) error {
+	return certError{fmt.Errorf(format, a...)}
+}
+
+// ValidateOptions controls Validate.
+type ValidateOptions struct {
+	// Now is the time at which certificates must be valid. Defaults to the current time.
+	Now time.Time
+	// Roots are the trusted root certificates. If Roots or Intermediates is set, the chain is
+	// verified; use x509.SystemCertPool for the system's roots.
+	Roots *x509.CertPool
+	// Intermediates are known intermediate certificates. A chain that only verifies with
+	// them is incomplete: Validate reports the ones it lacks.
+	Intermediates *x509.CertPool
+}
+
+// Info describes a validated certificate and its chain.
+type Info struct {
+	// Chain is the certificate chain, leaf first.
+	Chain      []*x509.Certificate `json:"-"`
+	CommonName string              `json:"common_name"`
+	// SubjectAltNames are the DNS names and IP addresses of the leaf certificate.
+	SubjectAltNames []string  `json:"san"`
+	NotBefore       time.Time `json:"starts"`
+	NotAfter        time.Time `json:"expires"`
+	Issuer          string    `json:"issuer"`
+}
+
+// Leaf returns the certificate of the chain that is not an issuer.
+func (i Info) Leaf() *x509.Certificate {
+	if len(i.Chain) == 0 {
+		return nil
+	}
+	return i.Chain[0]
+}
+
+// Names returns the names the certificate is valid for: its SubjectAltNames, or its
+// CommonName if it has none.
+func (i Info) Names() []string {
+	if len(i.SubjectAltNames) > 0 {
+		return i.SubjectAltNames
+	}
+	if i.CommonName != "" {
+		return []string{i.CommonName}
+	}
+	return nil
+}
+
+// Covers returns true if one of the certificate's names matches the domain, see MatchDomain.
+func (i Info) Covers(domain string) bool {
+	for _, name := range i.Names() {
+		if MatchDomain(name, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// PEM returns the chain PEM-encoded leaf first, as the controller expects it.
+func (i Info) PEM() string {
+	return EncodeChain(i.Chain)
+}
+
+// MatchDomain returns true if a certificate name matches a domain. A wildcard name such as
+// *.example.com matches exactly one label: it matches www.example.com, but neither
+// example.com nor a.b.example.com. A wildcard name also matches the same wildcard domain, as
+// wildcard domains are attached to apps as is. Names are compared case-insensitively.
+func MatchDomain(name string, domain string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if name == "" || domain == "" {
+		return false
+	}
+
+	if name == domain {
+		return true
+	}
+	if !strings.HasPrefix(name, "*.") {
+		return false
+	}
+	label, rest, ok := strings.Cut(domain, ".")
+	return ok && label != "" && label != "*" && rest == name[2:]
+}
+
+// Validate checks a certificate chain and private key locally, before they are sent to the
+// controller with New: the PEM data must parse, the key must be the leaf's, every certificate
+// must be valid at opts.Now and each must be issued by the next one. The chain may be in any
+// order; Info.PEM returns it leaf first. If opts has roots or intermediates, the chain is also
+// verified against them.
+//
+// Errors match deis.ErrInvalidCertificate and their precise cause, such as ErrKeyMismatch.
+func Validate(certPEM string, keyPEM string, opts ValidateOptions) (Info, error) {
+	certs, err := ParseChain([]byte(certPEM))
+	if err != nil {
+		return Info{}, err
+	}
+
+	chain, err := OrderChain(certs)
+	if err != nil {
+		return Info{}, err
+	}
+	leaf := chain[0]
+
+	key, err := ParsePrivateKey([]byte(keyPEM))
+	if err != nil {
+		return Info{}, err
+	}
+	if !KeyMatches(leaf, key) {
+		return Info{}, certError{ErrKeyMismatch}
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, cert := range chain {
+		if now.Before(cert.NotBefore) {
+			return Info{}, invalid("%w: %s is valid from %s", ErrNotYetValid, describe(cert), cert.NotBefore.UTC())
+		}
+		if now.After(cert.NotAfter) {
+			return Info{}, invalid("%w: %s expired on %s", ErrExpired, describe(cert), cert.NotAfter.UTC())
+		}
+	}
+
+	if opts.Roots != nil || opts.Intermediates != nil {
+		if err := verify(chain, now, opts); err != nil {
+			return Info{}, err
+		}
+	}
+
+	info := Info{
+		Chain:           chain,
+		CommonName:      leaf.Subject.CommonName,
+		SubjectAltNames: append([]string{}, leaf.DNSNames...),
+		NotBefore:       leaf.NotBefore,
+		NotAfter:        leaf.NotAfter,
+		Issuer:          leaf.Issuer.String(),
+	}
+	for _, ip := range leaf.IPAddresses {
+		info.SubjectAltNames = append(info.SubjectAltNames, ip.String())
+	}
+	return info, nil
+}
+
+// ParseChain parses the certificates of PEM data, in order. Other PEM blocks are ignored.
+func ParseChain(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, invalid("certificate %d: %w", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, certError{ErrNoCertificate}
+	}
+	return certs, nil
+}
+
+// OrderChain orders certificates leaf first, each followed by its issuer. The leaf is the only
+// certificate that issues none of the others. Every certificate must belong to the chain.
+func OrderChain(certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, certError{ErrNoCertificate}
+	}
+
+	var leaves []*x509.Certificate
+	for _, cert := range certs {
+		if issuerOf(certs, cert) == nil {
+			leaves = append(leaves, cert)
+		}
+	}
+	if len(leaves) != 1 {
+		return nil, invalid("%w: %d certificates are not the issuer of another one", ErrInvalidChain, len(leaves))
+	}
+
+	chain := []*x509.Certificate{leaves[0]}
+	for len(chain) < len(certs) {
+		last := chain[len(chain)-1]
+		issuer := issuedBy(certs, last)
+		if issuer == nil || issuer == last {
+			break
+		}
+		chain = append(chain, issuer)
+	}
+	if len(chain) != len(certs) {
+		for _, cert := range certs {
+			if !contains(chain, cert) {
+				return nil, invalid("%w: %s does not issue %s", ErrInvalidChain, describe(cert), describe(chain[len(chain)-1]))
+			}
+		}
+	}
+	return chain, nil
+}
+
+// EncodeChain PEM-encodes certificates in order.
+func EncodeChain(chain []*x509.Certificate) string {
+	var b bytes.Buffer
+	for _, cert := range chain {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return b.String()
+}
+
+// ParsePrivateKey parses the first private key of PEM data, in PKCS#1, PKCS#8 or SEC 1 (EC)
+// format. Encrypted keys are not supported.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, certError{ErrNoPrivateKey}
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		if _, ok := block.Headers["DEK-Info"]; ok || block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, invalid("%w: encrypted private keys are not supported", ErrNoPrivateKey)
+		}
+
+		key, err := parseKeyDER(block.Type, block.Bytes)
+		if err != nil {
+			return nil, invalid("%s: %w", strings.ToLower(block.Type), err)
+		}
+		return key, nil
+	}
+}
+
+// KeyMatches returns true if key is the private key of the certificate.
+func KeyMatches(cert *x509.Certificate, key crypto.Signer) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
+
+func parseKeyDER(blockType string, der []byte) (crypto.Signer, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		// Some tools label PKCS#1 and SEC 1 keys as PKCS#8 ones.
+		if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(der); rsaErr == nil {
+			return rsaKey, nil
+		}
+		if ecKey, ecErr := x509.ParseECPrivateKey(der); ecErr == nil {
+			return ecKey, nil
+		}
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// verify verifies a chain against the roots. If it does not verify, but its top certificate
+// does with the intermediates of opts, it reports the intermediates the chain lacks.
+func verify(chain []*x509.Certificate, now time.Time, opts ValidateOptions) error {
+	given := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		given.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{Roots: opts.Roots, Intermediates: given, CurrentTime: now})
+	if err == nil {
+		return nil
+	}
+
+	top := chain[len(chain)-1]
+	if opts.Intermediates != nil && !isSelfSigned(top) {
+		verified, topErr := top.Verify(x509.VerifyOptions{Roots: opts.Roots, Intermediates: opts.Intermediates,
+			CurrentTime: now, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+		if topErr == nil {
+			var missing []string
+			for _, cert := range verified[0][1:] {
+				if !isSelfSigned(cert) {
+					missing = append(missing, describe(cert))
+				}
+			}
+			return invalid("%w: %s", ErrIncompleteChain, strings.Join(missing, ", "))
+		}
+	}
+	return invalid("%w: %v", ErrUntrustedChain, err)
+}
+
+// issuerOf returns the certificate of certs that cert issues, if any.
+func issuerOf(certs []*x509.Certificate, cert *x509.Certificate) *x509.Certificate {
+	for _, other := range certs {
+		if other != cert && issues(cert, other) {
+			return other
+		}
+	}
+	return nil
+}
+
+// issuedBy returns the issuer of cert among certs, if any.
+func issuedBy(certs []*x509.Certificate, cert *x509.Certificate) *x509.Certificate {
+	for _, other := range certs {
+		if other != cert && issues(other, cert) {
+			return other
+		}
+	}
+	return nil
+}
+
+func issues(issuer *x509.Certificate, cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuer.RawSubject) && cert.CheckSignatureFrom(issuer) == nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func contains(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return fmt.Sprintf("%q", cert.Subject.CommonName)
+	}
+	return fmt.Sprintf("%q", cert.Subject.String())
+}
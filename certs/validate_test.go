@@ -0,0 +1,247 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+)
+
+// testCert is a certificate and key generated for tests.
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func (tc testCert) pem() string {
+	return EncodeChain([]*x509.Certificate{tc.cert})
+}
+
+func (tc testCert) keyPEM(t *testing.T) string {
+	der, err := x509.MarshalPKCS8PrivateKey(tc.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// newTestCert generates a certificate for names, issued by parent or self-signed if parent is
+// nil. The first name is the common name; certificates named "CA ..." are CAs.
+func newTestCert(t *testing.T, parent *testCert, notBefore time.Time, notAfter time.Time, names ...string) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if strings.HasPrefix(names[0], "CA ") {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.DNSNames = names[1:]
+	}
+
+	issuer, signer := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, key: key}
+}
+
+// testChain is a root, an intermediate and a leaf for example.com valid for a year.
+type testChain struct {
+	root, intermediate, leaf testCert
+}
+
+func newTestChain(t *testing.T, names ...string) testChain {
+	start, end := time.Now().Add(-time.Hour), time.Now().AddDate(1, 0, 0)
+	if len(names) == 0 {
+		names = []string{"example.com", "example.com", "*.example.com"}
+	}
+
+	root := newTestCert(t, nil, start, end, "CA Root")
+	intermediate := newTestCert(t, &root, start, end, "CA Intermediate")
+	leaf := newTestCert(t, &intermediate, start, end, names...)
+	return testChain{root: root, intermediate: intermediate, leaf: leaf}
+}
+
+func (tc testChain) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(tc.root.cert)
+	return pool
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tc := newTestChain(t)
+
+	// The chain is given out of order.
+	info, err := Validate(tc.intermediate.pem()+tc.leaf.pem(), tc.leaf.keyPEM(t), ValidateOptions{Roots: tc.roots()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.PEM() != tc.leaf.pem()+tc.intermediate.pem() {
+		t.Errorf("Expected the chain leaf first, Got %s", info.PEM())
+	}
+	if info.CommonName != "example.com" || !reflect.DeepEqual([]string{"example.com", "*.example.com"}, info.SubjectAltNames) {
+		t.Errorf("Unexpected names %s %v", info.CommonName, info.SubjectAltNames)
+	}
+	if !info.NotAfter.Equal(tc.leaf.cert.NotAfter) || info.Issuer != "CN=CA Intermediate" || info.Leaf() != info.Chain[0] {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if !info.Covers("www.example.com") || info.Covers("a.b.example.com") {
+		t.Errorf("Unexpected coverage of %v", info.Names())
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	t.Parallel()
+
+	tc := newTestChain(t)
+	other := newTestChain(t)
+	past := time.Now().AddDate(-1, 0, 0)
+	expired := newTestCert(t, &tc.intermediate, past, past.Add(time.Hour), "expired.example.com")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(tc.intermediate.cert)
+
+	tests := []struct {
+		name     string
+		cert     string
+		key      string
+		opts     ValidateOptions
+		expected error
+	}{
+		{"no certificate", "not a certificate", tc.leaf.keyPEM(t), ValidateOptions{}, ErrNoCertificate},
+		{"no key", tc.leaf.pem(), tc.leaf.pem(), ValidateOptions{}, ErrNoPrivateKey},
+		{"key mismatch", tc.leaf.pem(), other.leaf.keyPEM(t), ValidateOptions{}, ErrKeyMismatch},
+		{"expired", expired.pem(), expired.keyPEM(t), ValidateOptions{}, ErrExpired},
+		{"not yet valid", tc.leaf.pem(), tc.leaf.keyPEM(t), ValidateOptions{Now: past}, ErrNotYetValid},
+		{"foreign certificate", tc.leaf.pem() + other.intermediate.pem(), tc.leaf.keyPEM(t), ValidateOptions{}, ErrInvalidChain},
+		{"missing intermediate", tc.leaf.pem(), tc.leaf.keyPEM(t), ValidateOptions{Roots: tc.roots(), Intermediates: pool},
+			ErrIncompleteChain},
+		{"untrusted", tc.leaf.pem() + tc.intermediate.pem(), tc.leaf.keyPEM(t), ValidateOptions{Roots: other.roots()},
+			ErrUntrustedChain},
+	}
+
+	for _, test := range tests {
+		_, err := Validate(test.cert, test.key, test.opts)
+		if !errors.Is(err, test.expected) || !errors.Is(err, deis.ErrInvalidCertificate) {
+			t.Errorf("%s: Expected %v, Got %v", test.name, test.expected, err)
+		}
+	}
+
+	_, err := Validate(tc.leaf.pem(), tc.leaf.keyPEM(t), ValidateOptions{Roots: tc.roots(), Intermediates: pool})
+	if err == nil || !strings.Contains(err.Error(), `"CA Intermediate"`) {
+		t.Errorf("Expected the missing intermediate in the error, Got %v", err)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		block    *pem.Block
+		expected crypto.Signer
+	}{
+		{&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, rsaKey},
+		{&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}, ecKey},
+		{&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}, rsaKey},
+	}
+
+	for _, test := range tests {
+		key, err := ParsePrivateKey(pem.EncodeToMemory(test.block))
+		if err != nil {
+			t.Errorf("%s: %v", test.block.Type, err)
+			continue
+		}
+		if !reflect.DeepEqual(key.Public(), test.expected.Public()) {
+			t.Errorf("%s: Expected the generated key", test.block.Type)
+		}
+	}
+
+	encrypted := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8DER})
+	if _, err := ParsePrivateKey(encrypted); !errors.Is(err, ErrNoPrivateKey) {
+		t.Errorf("Expected %v, Got %v", ErrNoPrivateKey, err)
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		domain   string
+		expected bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM", "example.com.", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "wwwexample.com", false},
+		{"*.example.com", "*.example.com", true},
+		{"*.Example.com", "*.example.com.", true},
+		{"*.example.com", "*.www.example.com", false},
+		{"example.com", "*.example.com", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		if actual := MatchDomain(test.name, test.domain); actual != test.expected {
+			t.Errorf("MatchDomain(%q, %q): Expected %t, Got %t", test.name, test.domain, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+// ApplyBundle decrypts an encrypted bundle locally with one of keys and sets its values on
+// the app, in a single release. Keys missing from the bundle, and keys whose value is
+// secrets.Mask because they were exported masked, are left unchanged.
+// Decrypted values are only ever sent to the controller.
+func ApplyBundle(c *deis.Client, app string, b *secrets.Bundle, keys ...secrets.Key) (api.Config, error) {
+	values, err := secrets.Open(b, keys...)
+	if err != nil {
+		return api.Config{}, err
+	}
+	for key, value := range values {
+		if value == secrets.Mask {
+			delete(values, key)
+		}
+	}
+
+	return Set(c, app, api.Config{Values: values})
+}
+
+// ExportBundle encrypts an app's current config values into a bundle sealed with key.
+// Values are exported unmasked, secrets included, so that ApplyBundle restores them: the
+// bundle is encrypted, and can be kept in version control in place of the secrets.
+func ExportBundle(c *deis.Client, app string, key secrets.Key) (*secrets.Bundle, error) {
+	config, reqErr := List(c, app)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	b, err := secrets.Seal(config.Values, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, reqErr
+}
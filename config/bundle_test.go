@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+const bundleApplyExpected string = `{"values":{"DB_HOST":"db.example.com","DB_PASSWORD":"hunter2"}}`
+
+type bundleHTTPServer struct{}
+
+func (bundleHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/example-go/config/" && req.Method == "GET" {
+		res.Write([]byte(configFixture))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/bundle-test/config/" && req.Method == "GET" {
+		res.Write([]byte(`{"app": "bundle-test", "values": {"DB_HOST": "db.example.com", "DB_PASSWORD": "hunter2"}}`))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/bundle-test/config/" && req.Method == "POST" {
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			fmt.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+		}
+
+		if string(body) != bundleApplyExpected {
+			fmt.Printf("Expected '%s', Got '%s'\n", bundleApplyExpected, body)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(configFixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestApplyBundle(t *testing.T) {
+	t.Parallel()
+
+	handler := bundleHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := secrets.NewKey("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// API_TOKEN was exported masked, so it is left unchanged.
+	values := map[string]interface{}{"DB_HOST": "db.example.com", "DB_PASSWORD": "hunter2", "API_TOKEN": secrets.Mask}
+	b, err := secrets.Seal(values, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApplyBundle(client, "bundle-test", b, key); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := secrets.NewKey("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyBundle(client, "bundle-test", b, other); !errors.Is(err, secrets.ErrKeyNotFound) {
+		t.Errorf("Expected %v, Got %v", secrets.ErrKeyNotFound, err)
+	}
+}
+
+func TestExportBundle(t *testing.T) {
+	t.Parallel()
+
+	handler := bundleHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := secrets.NewKey("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ExportBundle(client, "example-go", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := secrets.Open(b, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"TEST": "testing", "FOO": "bar"}
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("Expected %v, Got %v", expected, values)
+	}
+}
+
+func TestExportBundleRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	handler := bundleHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := secrets.NewKey("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ExportBundle(client, "bundle-test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := secrets.Open(b, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"DB_HOST": "db.example.com", "DB_PASSWORD": "hunter2"}
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("Expected %v, Got %v", expected, values)
+	}
+
+	// The fake controller only accepts the secret's real value.
+	if _, err := ApplyBundle(client, "bundle-test", b, key); err != nil {
+		t.Fatal(err)
+	}
+}
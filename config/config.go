@@ -3,10 +3,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
 	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
 )
 
 // List lists an app's config.
@@ -27,6 +29,26 @@ func List(c *deis.Client, app string) (api.Config, error) {
 	return config, reqErr
 }
 
+// ErrConfigUnavailable is returned by GetCurrent when an app's current config is not the one
+// asked for.
+var ErrConfigUnavailable = errors.New("only the current config of an app is available")
+
+// GetCurrent retrieves an app's current config, like List, and checks that its UUID is uuid,
+// such as the config of a release. The controller has no lookup by UUID and only serves an
+// app's current config, so for any other config, such as the config of an earlier release,
+// GetCurrent returns an error matching ErrConfigUnavailable. If uuid is empty, it is not checked.
+func GetCurrent(c *deis.Client, app string, uuid string) (api.Config, error) {
+	config, reqErr := List(c, app)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return api.Config{}, reqErr
+	}
+
+	if uuid != "" && config.UUID != uuid {
+		return api.Config{}, fmt.Errorf("config %s of %s: %w", uuid, app, ErrConfigUnavailable)
+	}
+	return config, reqErr
+}
+
 // Set sets an app's config variables and creates a new release.
 // This is a patching operation, which means when you call Set() with an api.Config:
 //
@@ -38,7 +60,19 @@ func List(c *deis.Client, app string) (api.Config, error) {
 // Calling Set() with an empty api.Config will return a deis.ErrConflict.
 // Trying to unset a key that does not exist returns a deis.ErrUnprocessable.
 // Trying to set a tag that is not a label in the kubernetes cluster will return a deis.ErrTagNotFound.
+// Memory and CPU limits and healthchecks are validated with api.Config.ValidateLimits and
+// api.Config.ValidateHealthchecks before the request is sent.
+//
+// Values are sent verbatim, use SetResolved to resolve secret references first.
 func Set(c *deis.Client, app string, config api.Config) (api.Config, error) {
+	if err := config.ValidateLimits(); err != nil {
+		return api.Config{}, err
+	}
+
+	if err := config.ValidateHealthchecks(); err != nil {
+		return api.Config{}, err
+	}
+
 	body, err := json.Marshal(config)
 
 	if err != nil {
@@ -60,3 +94,41 @@ func Set(c *deis.Client, app string, config api.Config) (api.Config, error) {
 
 	return newConfig, reqErr
 }
+
+// SetResolved is like Set, but values that are secret references, such as
+// file:///run/secrets/db or env://DB_PASSWORD, are first resolved with resolver, so the
+// controller only ever receives the secrets. See secrets.Reference.
+// If resolver is nil, secrets.DefaultResolver is used.
+func SetResolved(c *deis.Client, app string, config api.Config, resolver *secrets.Resolver) (api.Config, error) {
+	if resolver == nil {
+		resolver = secrets.DefaultResolver
+	}
+	values, err := resolver.ResolveValues(config.Values)
+	if err != nil {
+		return api.Config{}, err
+	}
+	config.Values = values
+
+	return Set(c, app, config)
+}
+
+// MergeFunc is called by SetIfUnchanged when an app's config was modified since the caller
+// read it. It receives the app's current config and the caller's patch, and returns the patch
+// to retry with. Returning an error aborts the update with that error.
+type MergeFunc func(current api.Config, patch api.Config) (api.Config, error)
+
+// SetIfUnchanged sets an app's config only if it has not been modified since the caller read
+// it, as identified by the config's UUID, see deis.CompareAndSet. Every change of the config
+// creates a release, so a config modified by a deploy or a rollback is also reported, with a
+// deis.ErrModified, unless merge rebases the patch onto the current config.
+func SetIfUnchanged(c *deis.Client, app string, uuid string, patch api.Config, merge MergeFunc,
+	retries int) (api.Config, error) {
+	read := func() (api.Config, string, error) {
+		current, err := List(c, app)
+		return current, current.UUID, err
+	}
+	set := func(patch api.Config) (api.Config, error) {
+		return Set(c, app, patch)
+	}
+	return deis.CompareAndSet("config", uuid, patch, read, set, merge, retries)
+}
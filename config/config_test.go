@@ -1,15 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
 	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
 )
 
 const configFixture string = `
@@ -280,3 +284,170 @@ func TestConfigList(t *testing.T) {
 		t.Errorf("Expected %v, Got %v", expected, actual)
 	}
 }
+
+func TestConfigSetIfUnchanged(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configVars := api.Config{
+		Values: map[string]interface{}{
+			"TEST": "testing",
+			"FOO":  "bar",
+		},
+		Memory: map[string]interface{}{
+			"web": "1G",
+		},
+		CPU: map[string]interface{}{
+			"web": "1000",
+		},
+		Tags: map[string]interface{}{
+			"test": "tests",
+		},
+		Registry: map[string]interface{}{
+			"username": "bob",
+		},
+	}
+	currentUUID := "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+
+	actual, err := SetIfUnchanged(client, "example-go", currentUUID, configVars, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.UUID != currentUUID {
+		t.Errorf("Expected %s, Got %s", currentUUID, actual.UUID)
+	}
+
+	_, err = SetIfUnchanged(client, "example-go", "stale", configVars, nil, 3)
+	expectedErr := deis.ErrModified{Resource: "config", Expected: "stale", Actual: currentUUID}
+	if err != expectedErr {
+		t.Errorf("Expected %v, Got %v", expectedErr, err)
+	}
+
+	merges := 0
+	merge := func(current api.Config, patch api.Config) (api.Config, error) {
+		merges++
+		if current.UUID != currentUUID {
+			t.Errorf("Expected %s, Got %s", currentUUID, current.UUID)
+		}
+		return patch, nil
+	}
+
+	if _, err = SetIfUnchanged(client, "example-go", "stale", configVars, merge, 1); err != nil {
+		t.Fatal(err)
+	}
+	if merges != 1 {
+		t.Errorf("Expected 1 merge, Got %d", merges)
+	}
+
+	_, err = SetIfUnchanged(client, "example-go", "stale", configVars, merge, 0)
+	if !deis.IsErrModified(err) {
+		t.Errorf("Expected a deis.ErrModified without retries, Got %v", err)
+	}
+
+	abort := fmt.Errorf("conflicting change to FOO")
+	_, err = SetIfUnchanged(client, "example-go", "stale", configVars,
+		func(api.Config, api.Config) (api.Config, error) { return api.Config{}, abort }, 1)
+	if err != abort {
+		t.Errorf("Expected %v, Got %v", abort, err)
+	}
+}
+
+func TestConfigSetInvalidLimits(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Set(client, "example-go", api.Config{Memory: map[string]interface{}{"web": "200M/100M"}})
+	if !errors.Is(err, api.ErrRequestExceedsLimit) {
+		t.Errorf("Expected %v, Got %v", api.ErrRequestExceedsLimit, err)
+	}
+}
+
+func TestConfigSetHealthchecks(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe := &api.Healthcheck{TCPSocket: &api.TCPSocketProbe{Port: 5000}, PeriodSeconds: -1}
+	configVars := api.Config{Healthcheck: map[string]*api.Healthchecks{"web": {api.LivenessProbe: probe}}}
+	if _, err := Set(client, "example-go", configVars); !errors.Is(err, api.ErrInvalidHealthcheck) {
+		t.Errorf("Expected %v, Got %v", api.ErrInvalidHealthcheck, err)
+	}
+
+	// Zero settings are left for the cluster to default, so the request is sent.
+	probe.PeriodSeconds = 0
+	if _, err := Set(client, "example-go", configVars); err == nil || errors.Is(err, api.ErrInvalidHealthcheck) {
+		t.Errorf("Expected the controller to reject the request, Got %v", err)
+	}
+}
+
+func TestConfigSetResolved(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo")
+	if err := ioutil.WriteFile(path, []byte("bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configVars := api.Config{
+		Values:   map[string]interface{}{"TEST": "testing", "FOO": "file://" + path},
+		Memory:   map[string]interface{}{"web": "1G"},
+		CPU:      map[string]interface{}{"web": "1000"},
+		Tags:     map[string]interface{}{"test": "tests"},
+		Registry: map[string]interface{}{"username": "bob"},
+	}
+
+	if _, err := SetResolved(client, "example-go", configVars, nil); err != nil {
+		t.Fatal(err)
+	}
+	if configVars.Values["FOO"] != "file://"+path {
+		t.Error("Expected the config not to be modified")
+	}
+
+	// Set sends references verbatim, which the fake controller rejects.
+	if _, err := Set(client, "example-go", configVars); err == nil {
+		t.Error("Expected the reference not to be resolved")
+	}
+
+	configVars.Values["FOO"] = "file://" + filepath.Join(dir, "missing")
+	if _, err := SetResolved(client, "example-go", configVars, nil); !errors.Is(err, secrets.ErrSecretNotFound) {
+		t.Errorf("Expected %v, Got %v", secrets.ErrSecretNotFound, err)
+	}
+}
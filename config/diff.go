@@ -0,0 +1,230 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+// Mask replaces secret values in diffs.
+const Mask = secrets.Mask
+
+// Sections of an api.Config compared by Compare, in the order they are reported.
+const (
+	SectionValues      = "values"
+	SectionMemory      = "memory"
+	SectionCPU         = "cpu"
+	SectionTimeout     = "termination_grace_period"
+	SectionHealthcheck = "healthcheck"
+	SectionTags        = "tags"
+	SectionRegistry    = "registry"
+)
+
+var sections = []string{
+	SectionValues, SectionMemory, SectionCPU, SectionTimeout, SectionHealthcheck, SectionTags, SectionRegistry,
+}
+
+// ChangeType describes how an entry differs between two configs.
+type ChangeType string
+
+const (
+	// Added entries only exist in the second config.
+	Added ChangeType = "added"
+	// Removed entries only exist in the first config.
+	Removed ChangeType = "removed"
+	// Changed entries exist in both configs with different values.
+	Changed ChangeType = "changed"
+)
+
+// Change is a single difference between two configs.
+type Change struct {
+	// Section is the part of the config the entry belongs to, such as "values" or "memory".
+	Section string `json:"section"`
+	// Key is the config key, process type, or for healthchecks the process type and probe
+	// separated by a slash, as in "web/livenessProbe".
+	Key  string     `json:"key"`
+	Type ChangeType `json:"type"`
+	Old  string     `json:"old"`
+	New  string     `json:"new"`
+	// Masked is true if Old and New were replaced with Mask because the value is a secret.
+	Masked bool `json:"masked,omitempty"`
+}
+
+// Diff is the set of differences between two configs.
+type Diff struct {
+	// From and To describe the compared configs, for example with the app name.
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+// DiffOptions controls how configs are compared.
+type DiffOptions struct {
+	// ShowSecrets disables masking of secret values. By default, registry credentials and
+	// values classified as sensitive by Classifier are masked.
+	ShowSecrets bool
+	// Classifier decides which values are sensitive. If nil, secrets.Default is used.
+	Classifier *secrets.Classifier
+}
+
+// Empty returns true if the configs are equal.
+func (d Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// String renders the diff in unified diff format, with a hunk for each changed section.
+func (d Diff) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", d.From, d.To)
+
+	section := ""
+	for _, c := range d.Changes {
+		if c.Section != section {
+			section = c.Section
+			fmt.Fprintf(&buf, "@@ %s @@\n", section)
+		}
+		if c.Type != Added {
+			fmt.Fprintf(&buf, "-%s=%s\n", c.Key, c.Old)
+		}
+		if c.Type != Removed {
+			fmt.Fprintf(&buf, "+%s=%s\n", c.Key, c.New)
+		}
+	}
+
+	return buf.String()
+}
+
+// Compare returns the differences between two configs. Only the values, limits,
+// termination grace periods, healthchecks, tags and registry credentials are compared;
+// metadata such as the owner or UUID is ignored. From and To of the diff are set to the
+// configs' app names.
+func Compare(from api.Config, to api.Config, opts DiffOptions) Diff {
+	d := Diff{From: from.App, To: to.App, Changes: []Change{}}
+
+	for _, section := range sections {
+		oldFlat, newFlat := flatten(from, section), flatten(to, section)
+
+		keys := make([]string, 0, len(oldFlat)+len(newFlat))
+		for k := range oldFlat {
+			keys = append(keys, k)
+		}
+		for k := range newFlat {
+			if _, ok := oldFlat[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			o, inOld := oldFlat[k]
+			n, inNew := newFlat[k]
+
+			c := Change{Section: section, Key: k, Old: o, New: n}
+			switch {
+			case !inOld:
+				c.Type = Added
+			case !inNew:
+				c.Type = Removed
+			case o != n:
+				c.Type = Changed
+			default:
+				continue
+			}
+
+			if !opts.ShowSecrets && isSecret(opts.Classifier, section, k, o, n) {
+				c.Masked = true
+				if inOld {
+					c.Old = Mask
+				}
+				if inNew {
+					c.New = Mask
+				}
+			}
+
+			d.Changes = append(d.Changes, c)
+		}
+	}
+
+	return d
+}
+
+// CompareApps returns the differences between the current configs of two apps.
+func CompareApps(c *deis.Client, fromApp string, toApp string, opts DiffOptions) (Diff, error) {
+	from, err := List(c, fromApp)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return Diff{}, err
+	}
+
+	to, reqErr := List(c, toApp)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return Diff{}, reqErr
+	}
+
+	d := Compare(from, to, opts)
+	d.From, d.To = fromApp, toApp
+	return d, reqErr
+}
+
+// flatten returns a section of a config as a map of strings.
+func flatten(config api.Config, section string) map[string]string {
+	var values map[string]interface{}
+
+	switch section {
+	case SectionValues:
+		values = config.Values
+	case SectionMemory:
+		values = config.Memory
+	case SectionCPU:
+		values = config.CPU
+	case SectionTimeout:
+		values = config.Timeout
+	case SectionTags:
+		values = config.Tags
+	case SectionRegistry:
+		values = config.Registry
+	case SectionHealthcheck:
+		flat := make(map[string]string)
+		for procType, checks := range config.Healthcheck {
+			if checks == nil {
+				continue
+			}
+			for probe, check := range *checks {
+				if check == nil {
+					continue
+				}
+				b, err := json.Marshal(check)
+				if err != nil {
+					continue
+				}
+				flat[procType+"/"+probe] = string(b)
+			}
+		}
+		return flat
+	}
+
+	flat := make(map[string]string, len(values))
+	for k, v := range values {
+		if v == nil {
+			continue
+		}
+		flat[k] = valueString(v)
+	}
+	return flat
+}
+
+// isSecret returns true if an entry should be masked. A value is masked if either its old
+// or its new value is sensitive, so that a secret is not revealed by the other side of a change.
+func isSecret(classifier *secrets.Classifier, section string, key string, old string, new string) bool {
+	switch section {
+	case SectionRegistry:
+		return true
+	case SectionValues:
+		return classifier.IsSensitive(key, old) || classifier.IsSensitive(key, new)
+	}
+	return false
+}
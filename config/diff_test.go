@@ -0,0 +1,215 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+const stagingConfigFixture string = `
+{
+    "owner": "test",
+    "app": "staging",
+    "values": {
+      "DEBUG": "true",
+      "DB_HOST": "staging-db",
+      "DB_PASSWORD": "staging-secret",
+      "WORKERS": "4"
+    },
+    "memory": {
+      "web": "1G"
+    },
+    "registry": {
+      "bob": "hunter2"
+    },
+    "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+}
+`
+
+const productionConfigFixture string = `
+{
+    "owner": "test",
+    "app": "production",
+    "values": {
+      "DB_HOST": "production-db",
+      "DB_PASSWORD": "production-secret",
+      "WORKERS": "4",
+      "SENTRY_DSN": "https://sentry"
+    },
+    "memory": {
+      "web": "2G"
+    },
+    "healthcheck": {
+      "web": {
+        "livenessProbe": {
+          "initialDelaySeconds": 5,
+          "timeoutSeconds": 5,
+          "periodSeconds": 10,
+          "successThreshold": 1,
+          "failureThreshold": 3,
+          "httpGet": {"path": "/", "port": 5000}
+        }
+      }
+    },
+    "uuid": "a2b1ac54-2d3f-4ebd-8b48-4b2d7c6bbe73"
+}
+`
+
+type diffHTTPServer struct{}
+
+func (diffHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/staging/config/" && req.Method == "GET" {
+		res.Write([]byte(stagingConfigFixture))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/production/config/" && req.Method == "GET" {
+		res.Write([]byte(productionConfigFixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+const healthcheckJSON = `{"initialDelaySeconds":5,"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3,"httpGet":{"path":"/","port":5000}}`
+
+var expectedChanges = []Change{
+	{Section: SectionValues, Key: "DB_HOST", Type: Changed, Old: "staging-db", New: "production-db"},
+	{Section: SectionValues, Key: "DB_PASSWORD", Type: Changed, Old: Mask, New: Mask, Masked: true},
+	{Section: SectionValues, Key: "DEBUG", Type: Removed, Old: "true"},
+	{Section: SectionValues, Key: "SENTRY_DSN", Type: Added, New: "https://sentry"},
+	{Section: SectionMemory, Key: "web", Type: Changed, Old: "1G", New: "2G"},
+	{Section: SectionHealthcheck, Key: "web/livenessProbe", Type: Added, New: healthcheckJSON},
+	{Section: SectionRegistry, Key: "bob", Type: Removed, Old: Mask, Masked: true},
+}
+
+const expectedUnifiedDiff = `--- staging
++++ production
+@@ values @@
+-DB_HOST=staging-db
++DB_HOST=production-db
+-DB_PASSWORD=********
++DB_PASSWORD=********
+-DEBUG=true
++SENTRY_DSN=https://sentry
+@@ memory @@
+-web=1G
++web=2G
+@@ healthcheck @@
++web/livenessProbe=` + healthcheckJSON + `
+@@ registry @@
+-bob=********
+`
+
+func TestCompareApps(t *testing.T) {
+	t.Parallel()
+
+	handler := diffHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := CompareApps(deis, "staging", "production", DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expectedChanges, actual.Changes) {
+		t.Errorf("Expected %v, Got %v", expectedChanges, actual.Changes)
+	}
+
+	if actual.String() != expectedUnifiedDiff {
+		t.Errorf("Expected:\n\n%s\n\nGot:\n\n%s", expectedUnifiedDiff, actual.String())
+	}
+
+	b, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Diff
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, decoded) {
+		t.Errorf("Expected %v, Got %v", actual, decoded)
+	}
+}
+
+func TestGetCurrent(t *testing.T) {
+	t.Parallel()
+
+	handler := diffHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := GetCurrent(deis, "staging", "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.UUID != "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75" {
+		t.Errorf("Expected the current config, Got %+v", actual)
+	}
+
+	if _, err := GetCurrent(deis, "staging", "a2b1ac54-2d3f-4ebd-8b48-4b2d7c6bbe73"); !errors.Is(err, ErrConfigUnavailable) {
+		t.Errorf("Expected %v, Got %v", ErrConfigUnavailable, err)
+	}
+
+	if actual, err := GetCurrent(deis, "staging", ""); err != nil || actual.UUID != "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75" {
+		t.Errorf("Expected the current config, Got %+v %v", actual, err)
+	}
+}
+
+func TestCompareEqual(t *testing.T) {
+	t.Parallel()
+
+	config := api.Config{
+		App:    "example-go",
+		Values: map[string]interface{}{"FOO": "bar"},
+		CPU:    map[string]interface{}{"web": "500m"},
+	}
+
+	d := Compare(config, config, DiffOptions{})
+	if !d.Empty() {
+		t.Errorf("Expected no changes, Got %v", d.Changes)
+	}
+
+	if d.String() != "--- example-go\n+++ example-go\n" {
+		t.Errorf("Unexpected diff:\n%s", d)
+	}
+}
+
+func TestChangeJSONKeepsEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(Change{Section: SectionValues, Key: "DEBUG", Type: Changed, Old: "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"section":"values","key":"DEBUG","type":"changed","old":"true","new":""}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, Got %s", expected, b)
+	}
+}
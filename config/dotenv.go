@@ -0,0 +1,438 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+// ErrMaskedValue is returned when a dotenv file sets a key the app does not have to
+// secrets.Mask, whose real value is unknown.
+var ErrMaskedValue = errors.New("masked value for a key that is not set")
+
+// keyRegex matches the config keys accepted by the controller.
+var keyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// unquotedRegex matches values that can be written to a dotenv file without quotes.
+var unquotedRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// DotenvSyntaxError is returned when a dotenv file cannot be parsed.
+type DotenvSyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e DotenvSyntaxError) Error() string {
+	return fmt.Sprintf("dotenv syntax error on line %d: %s", e.Line, e.Msg)
+}
+
+// ParseDotenv parses a dotenv file into an ordered list of key/value pairs.
+//
+// Blank lines and lines starting with # are ignored, and a leading "export " is allowed.
+// Unquoted values are trimmed and end at a # preceded by whitespace. Single quoted values
+// are taken literally. Double quoted values support the escapes \n, \r, \t, \", \\ and \$.
+// Both quoted forms may span multiple lines. If a key appears more than once, it keeps the
+// position of its first occurrence and the value of its last.
+func ParseDotenv(r io.Reader) ([]api.KVPair, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := dotenvParser{src: strings.Replace(string(data), "\r\n", "\n", -1), line: 1}
+	var pairs []api.KVPair
+	index := make(map[string]int)
+
+	for {
+		pair, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return pairs, nil
+		}
+		if i, exists := index[pair.Name]; exists {
+			pairs[i].Value = pair.Value
+			continue
+		}
+		index[pair.Name] = len(pairs)
+		pairs = append(pairs, pair)
+	}
+}
+
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *dotenvParser) errorf(line int, format string, args ...interface{}) error {
+	return DotenvSyntaxError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// readLine returns the rest of the current line and moves past its newline.
+func (p *dotenvParser) readLine() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		s := p.src[p.pos:]
+		p.pos = len(p.src)
+		return s
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	p.line++
+	return s
+}
+
+// next returns the next key/value pair, or false at the end of the input.
+func (p *dotenvParser) next() (api.KVPair, bool, error) {
+	for p.pos < len(p.src) {
+		start, lineNo := p.pos, p.line
+		line := strings.TrimLeft(p.readLine(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimLeft(line[len("export"):], " \t")
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return api.KVPair{}, false, p.errorf(lineNo, "expected KEY=VALUE, got %q", line)
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		if !keyRegex.MatchString(key) {
+			return api.KVPair{}, false, p.errorf(lineNo, "invalid key %q", key)
+		}
+
+		rest := strings.TrimLeft(line[eq+1:], " \t")
+		if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+			return api.KVPair{Name: key, Value: unquoted(rest)}, true, nil
+		}
+
+		// Quoted values may span lines, so rewind to just after the opening quote.
+		p.pos = start + strings.IndexByte(p.src[start:], rest[0]) + 1
+		p.line = lineNo
+		value, err := p.quoted(rest[0])
+		if err != nil {
+			return api.KVPair{}, false, err
+		}
+		return api.KVPair{Name: key, Value: value}, true, nil
+	}
+
+	return api.KVPair{}, false, nil
+}
+
+// quoted reads a quoted value up to the closing quote and checks that nothing but a comment
+// follows it.
+func (p *dotenvParser) quoted(quote byte) (string, error) {
+	var value bytes.Buffer
+	startLine := p.line
+
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf(startLine, "unterminated quoted value")
+		}
+
+		ch := p.src[p.pos]
+		p.pos++
+
+		switch {
+		case ch == quote:
+			lineNo := p.line
+			trailing := strings.TrimSpace(p.readLine())
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return "", p.errorf(lineNo, "unexpected %q after quoted value", trailing)
+			}
+			return value.String(), nil
+		case ch == '\\' && quote == '"' && p.pos < len(p.src):
+			esc := p.src[p.pos]
+			p.pos++
+			switch esc {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '"', '\\', '$':
+				value.WriteByte(esc)
+			case '\n':
+				// A backslash at the end of a line continues the value on the next one.
+				p.line++
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(esc)
+			}
+		default:
+			if ch == '\n' {
+				p.line++
+			}
+			value.WriteByte(ch)
+		}
+	}
+}
+
+// unquoted strips an inline comment and surrounding whitespace from an unquoted value.
+func unquoted(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// FormatDotenv writes key/value pairs in dotenv format, in the order they are given.
+// Values are double quoted and escaped when they contain characters other than letters,
+// digits and common punctuation, so that ParseDotenv returns them unchanged.
+func FormatDotenv(w io.Writer, pairs []api.KVPair) error {
+	bw := bufio.NewWriter(w)
+	for _, pair := range pairs {
+		if !keyRegex.MatchString(pair.Name) {
+			return fmt.Errorf("invalid key %q", pair.Name)
+		}
+		if _, err := fmt.Fprintf(bw, "%s=%s\n", pair.Name, quoteDotenv(pair.Value)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func quoteDotenv(s string) string {
+	if unquotedRegex.MatchString(s) {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// DotenvPairs returns an app's config values as key/value pairs. Keys listed in order come
+// first, in that order; the remaining keys follow sorted alphabetically. Keys in order that
+// are not set in values are dropped.
+func DotenvPairs(values map[string]interface{}, order []string) []api.KVPair {
+	pairs := make([]api.KVPair, 0, len(values))
+	seen := make(map[string]bool, len(values))
+
+	for _, key := range order {
+		if v, ok := values[key]; ok && !seen[key] {
+			pairs = append(pairs, api.KVPair{Name: key, Value: valueString(v)})
+			seen[key] = true
+		}
+	}
+
+	var rest []string
+	for key := range values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		pairs = append(pairs, api.KVPair{Name: key, Value: valueString(values[key])})
+	}
+
+	return pairs
+}
+
+// DotenvDiff returns the config that changes an app's current values into pairs.
+// Keys that are new or whose value differs are set, keys missing from pairs are unset
+// (set to nil) and unchanged keys are left out. The returned config has no values if
+// nothing changed.
+// Keys whose value is secrets.Mask, as written by MaskDotenv, are left unchanged so that
+// pushing a masked export back never overwrites the secrets it hides, and keys the app does
+// not have are not set to the mask; see MaskedKeys.
+func DotenvDiff(current map[string]interface{}, pairs []api.KVPair) api.Config {
+	values := make(map[string]interface{})
+	wanted := make(map[string]bool, len(pairs))
+
+	for _, pair := range pairs {
+		wanted[pair.Name] = true
+		v, ok := current[pair.Name]
+		if pair.Value == secrets.Mask {
+			continue
+		}
+		if !ok || valueString(v) != pair.Value {
+			values[pair.Name] = pair.Value
+		}
+	}
+
+	for key := range current {
+		if !wanted[key] {
+			values[key] = nil
+		}
+	}
+
+	if len(values) == 0 {
+		return api.Config{}
+	}
+	return api.Config{Values: values}
+}
+
+// MaskedKeys returns the keys of pairs whose value is secrets.Mask but that are not set in
+// current, such as secrets unset since the file was pulled. DotenvDiff leaves them out, as
+// the values they hide are unknown.
+func MaskedKeys(current map[string]interface{}, pairs []api.KVPair) []string {
+	var keys []string
+	for _, pair := range pairs {
+		if _, ok := current[pair.Name]; !ok && pair.Value == secrets.Mask {
+			keys = append(keys, pair.Name)
+		}
+	}
+	return keys
+}
+
+// MaskDotenv returns a copy of pairs with the values that classifier considers sensitive
+// replaced by secrets.Mask, for displaying or sharing an app's config.
+// If classifier is nil, secrets.Default is used.
+func MaskDotenv(pairs []api.KVPair, classifier *secrets.Classifier) []api.KVPair {
+	masked := make([]api.KVPair, len(pairs))
+	for i, pair := range pairs {
+		masked[i] = api.KVPair{Name: pair.Name, Value: classifier.MaskValue(pair.Name, pair.Value)}
+	}
+	return masked
+}
+
+// PushDotenv makes an app's config values match the dotenv file at path, setting new and
+// changed keys and unsetting keys that are not in the file, in a single release.
+// Values that are secret references are resolved with secrets.DefaultResolver.
+// If the app's config already matches the file, no release is created and the current
+// config is returned. Masked values of keys the app does not have return an error matching
+// ErrMaskedValue.
+func PushDotenv(c *deis.Client, app string, path string) (api.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return api.Config{}, err
+	}
+	defer f.Close()
+
+	pairs, err := ParseDotenv(f)
+	if err != nil {
+		return api.Config{}, err
+	}
+
+	for i, pair := range pairs {
+		if pairs[i].Value, err = secrets.DefaultResolver.Resolve(pair.Value); err != nil {
+			return api.Config{}, fmt.Errorf("%s: %w", pair.Name, err)
+		}
+	}
+
+	current, err := List(c, app)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Config{}, err
+	}
+
+	if masked := MaskedKeys(current.Values, pairs); len(masked) > 0 {
+		return api.Config{}, fmt.Errorf("%w: %s", ErrMaskedValue, strings.Join(masked, ", "))
+	}
+
+	diff := DotenvDiff(current.Values, pairs)
+	if len(diff.Values) == 0 {
+		return current, err
+	}
+
+	return Set(c, app, diff)
+}
+
+// ExportOptions controls how an app's config values are exported in plaintext by PullDotenv.
+type ExportOptions struct {
+	// ShowSecrets disables masking of secret values. By default, values classified as
+	// sensitive by Classifier are exported as secrets.Mask.
+	ShowSecrets bool
+	// Classifier decides which values are sensitive. If nil, secrets.Default is used.
+	Classifier *secrets.Classifier
+}
+
+// values returns the config values to export, masked unless ShowSecrets is set.
+func (o ExportOptions) values(values map[string]interface{}) map[string]interface{} {
+	if o.ShowSecrets {
+		return values
+	}
+	return o.Classifier.MaskValues(values)
+}
+
+// PullDotenv writes an app's config values to the dotenv file at path.
+// If the file already exists, keys keep the order they have in it and new keys are appended
+// in alphabetical order. Comments in an existing file are not preserved.
+// Keys that are secret references in the existing file keep their reference as long as it
+// still resolves to the app's value, so secrets are not written to the file. Other sensitive
+// values are written as secrets.Mask unless opts.ShowSecrets is set; PushDotenv leaves them
+// unchanged.
+// The file is written with permissions 0600, even if it already exists, as config values
+// commonly hold secrets.
+func PullDotenv(c *deis.Client, app string, path string, opts ExportOptions) error {
+	config, reqErr := List(c, app)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return reqErr
+	}
+
+	var order []string
+	refs := make(map[string]string)
+	if f, err := os.Open(path); err == nil {
+		existing, err := ParseDotenv(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for _, pair := range existing {
+			order = append(order, pair.Name)
+			refs[pair.Name] = pair.Value
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	values := secrets.DefaultResolver.Unresolve(config.Values, refs)
+	masked := opts.values(config.Values)
+	for key, value := range values {
+		// References are kept, they do not reveal the secret.
+		if valueString(value) == valueString(config.Values[key]) {
+			values[key] = masked[key]
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := FormatDotenv(&buf, DotenvPairs(values, order)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// An existing file keeps its permissions, so restrict them before writing.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return reqErr
+}
+
+func valueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
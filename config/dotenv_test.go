@@ -0,0 +1,424 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/secrets"
+)
+
+const dotenvFixture string = `# database settings
+export DB_HOST=db.example.com
+DB_PORT = 5432 # default port
+DB_PASSWORD='p@ss#word $HOME'
+GREETING="hello\n\"world\""
+CERT="-----BEGIN-----
+abc
+-----END-----"
+EMPTY=
+DB_PORT=5433
+`
+
+const dotenvConfigFixture string = `
+{
+    "owner": "test",
+    "app": "dotenv-test",
+    "values": {
+      "DB_HOST": "db.example.com",
+      "DB_PORT": "5432",
+      "OLD": "value"
+    },
+    "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+}
+`
+
+const dotenvSecretFixture string = `
+{
+    "owner": "test",
+    "app": "dotenv-secret",
+    "values": {
+      "DB_HOST": "db.example.com",
+      "DB_PASSWORD": "hunter2"
+    },
+    "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+}
+`
+
+const dotenvPushExpected string = `{"values":{"DB_PORT":"5433","NEW":"a b","OLD":null}}`
+
+type dotenvHTTPServer struct{}
+
+func (dotenvHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/dotenv-test/config/" && req.Method == "GET" {
+		res.Write([]byte(dotenvConfigFixture))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/dotenv-secret/config/" && req.Method == "GET" {
+		res.Write([]byte(dotenvSecretFixture))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/dotenv-test/config/" && req.Method == "POST" {
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			fmt.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+		}
+
+		if string(body) != dotenvPushExpected {
+			fmt.Printf("Expected '%s', Got '%s'\n", dotenvPushExpected, body)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(dotenvConfigFixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+
+	expected := []api.KVPair{
+		{Name: "DB_HOST", Value: "db.example.com"},
+		{Name: "DB_PORT", Value: "5433"},
+		{Name: "DB_PASSWORD", Value: "p@ss#word $HOME"},
+		{Name: "GREETING", Value: "hello\n\"world\""},
+		{Name: "CERT", Value: "-----BEGIN-----\nabc\n-----END-----"},
+		{Name: "EMPTY", Value: ""},
+	}
+
+	actual, err := ParseDotenv(strings.NewReader(dotenvFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input string
+		Line  int
+	}{
+		{"FOO=bar\nnot a pair\n", 2},
+		{"FOO=bar\n1FOO=bar\n", 2},
+		{"FOO=bar\nBAR=\"unterminated\nvalue\n", 2},
+		{"FOO=\"bar\nbaz\" trailing\n", 2},
+		{"FOO='bar' trailing", 1},
+	}
+
+	for _, test := range tests {
+		_, err := ParseDotenv(strings.NewReader(test.Input))
+		syntaxErr, ok := err.(DotenvSyntaxError)
+		if !ok {
+			t.Errorf("%q: Expected a DotenvSyntaxError, Got %v", test.Input, err)
+			continue
+		}
+		if syntaxErr.Line != test.Line {
+			t.Errorf("%q: Expected error on line %d, Got %v", test.Input, test.Line, err)
+		}
+	}
+}
+
+func TestFormatDotenvRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	pairs := []api.KVPair{
+		{Name: "PLAIN", Value: "postgres://user@host:5432/db"},
+		{Name: "SPACES", Value: "a b"},
+		{Name: "QUOTES", Value: `say "hi" \o/`},
+		{Name: "DOLLAR", Value: "$HOME"},
+		{Name: "MULTILINE", Value: "line1\nline2\ttabbed"},
+		{Name: "HASH", Value: "a #b"},
+		{Name: "EMPTY", Value: ""},
+	}
+
+	var buf bytes.Buffer
+	if err := FormatDotenv(&buf, pairs); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "PLAIN=postgres://user@host:5432/db\nSPACES=\"a b\"\n") {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+
+	actual, err := ParseDotenv(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(pairs, actual) {
+		t.Errorf("Expected %v, Got %v", pairs, actual)
+	}
+
+	if err := FormatDotenv(&buf, []api.KVPair{{Name: "BAD KEY", Value: "x"}}); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
+
+func TestDotenvPairs(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{"C": "3", "A": "1", "B": "2", "D": 4}
+	expected := []api.KVPair{
+		{Name: "C", Value: "3"},
+		{Name: "A", Value: "1"},
+		{Name: "B", Value: "2"},
+		{Name: "D", Value: "4"},
+	}
+
+	actual := DotenvPairs(values, []string{"C", "GONE", "A"})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestMaskDotenv(t *testing.T) {
+	t.Parallel()
+
+	pairs := []api.KVPair{{Name: "DB_HOST", Value: "db"}, {Name: "DB_PASSWORD", Value: "hunter2"}}
+	masked := MaskDotenv(pairs, nil)
+
+	expected := []api.KVPair{{Name: "DB_HOST", Value: "db"}, {Name: "DB_PASSWORD", Value: Mask}}
+	if !reflect.DeepEqual(expected, masked) {
+		t.Errorf("Expected %v, Got %v", expected, masked)
+	}
+	if pairs[1].Value != "hunter2" {
+		t.Error("Expected the pairs not to be modified")
+	}
+
+	// Pushing a masked export back keeps the current secrets.
+	current := map[string]interface{}{"DB_HOST": "old", "DB_PASSWORD": "hunter2"}
+	diff := DotenvDiff(current, masked)
+	if !reflect.DeepEqual(map[string]interface{}{"DB_HOST": "db"}, diff.Values) {
+		t.Errorf("Expected only DB_HOST to change, Got %v", diff.Values)
+	}
+}
+
+func TestDotenvDiffMaskedNewKey(t *testing.T) {
+	t.Parallel()
+
+	// API_TOKEN was pulled masked and has since been unset on the app.
+	current := map[string]interface{}{"DB_HOST": "db"}
+	pairs := []api.KVPair{{Name: "DB_HOST", Value: "db"}, {Name: "API_TOKEN", Value: Mask}, {Name: "NEW", Value: "x"}}
+
+	diff := DotenvDiff(current, pairs)
+	if !reflect.DeepEqual(map[string]interface{}{"NEW": "x"}, diff.Values) {
+		t.Errorf("Expected only NEW to be added, Got %v", diff.Values)
+	}
+	if keys := MaskedKeys(current, pairs); !reflect.DeepEqual([]string{"API_TOKEN"}, keys) {
+		t.Errorf("Expected [API_TOKEN], Got %v", keys)
+	}
+}
+
+func TestPushDotenv(t *testing.T) {
+	t.Parallel()
+
+	handler := dotenvHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	if err := ioutil.WriteFile(path, []byte("DB_HOST=db.example.com\nDB_PORT=5433\nNEW=\"a b\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PushDotenv(deis, "dotenv-test", path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pushing a file that matches the current config doesn't create a release.
+	if err := ioutil.WriteFile(path, []byte("OLD=value\nDB_PORT=5432\nDB_HOST=db.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PushDotenv(deis, "dotenv-test", path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("OLD=value\nDB_PORT=5432\nDB_HOST=db.example.com\nAPI_TOKEN="+Mask+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PushDotenv(deis, "dotenv-test", path); !errors.Is(err, ErrMaskedValue) || !strings.Contains(err.Error(), "API_TOKEN") {
+		t.Errorf("Expected %v for API_TOKEN, Got %v", ErrMaskedValue, err)
+	}
+}
+
+func TestPullDotenv(t *testing.T) {
+	t.Parallel()
+
+	handler := dotenvHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	if err := PullDotenv(deis, "dotenv-test", path, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DB_HOST=db.example.com\nDB_PORT=5432\nOLD=value\n"
+	if actual, _ := ioutil.ReadFile(path); string(actual) != expected {
+		t.Errorf("Expected %q, Got %q", expected, actual)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("# comment\nOLD=x\nREMOVED=y\nDB_PORT=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PullDotenv(deis, "dotenv-test", path, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, Got %v %v", info, err)
+	}
+
+	expected = "OLD=value\nDB_PORT=5432\nDB_HOST=db.example.com\n"
+	if actual, _ := ioutil.ReadFile(path); string(actual) != expected {
+		t.Errorf("Expected %q, Got %q", expected, actual)
+	}
+}
+
+func TestPullDotenvMasksSecrets(t *testing.T) {
+	t.Parallel()
+
+	handler := dotenvHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	if err := PullDotenv(client, "dotenv-secret", path, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DB_HOST=db.example.com\nDB_PASSWORD=" + quoteDotenv(secrets.Mask) + "\n"
+	if actual, _ := ioutil.ReadFile(path); string(actual) != expected {
+		t.Errorf("Expected %q, Got %q", expected, actual)
+	}
+
+	if err := PullDotenv(client, "dotenv-secret", path, ExportOptions{ShowSecrets: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "DB_HOST=db.example.com\nDB_PASSWORD=hunter2\n"
+	if actual, _ := ioutil.ReadFile(path); string(actual) != expected {
+		t.Errorf("Expected %q, Got %q", expected, actual)
+	}
+}
+
+func TestDotenvReferences(t *testing.T) {
+	t.Parallel()
+
+	handler := dotenvHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := func(name string, value string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return "file://" + p
+	}
+	oldRef, hostRef, portRef := secret("old", "value\n"), secret("host", "db.example.com"), secret("port", "1")
+
+	// References are resolved before comparing, so only DB_PORT, NEW and OLD change.
+	path := filepath.Join(dir, ".env")
+	content := fmt.Sprintf("DB_HOST=%s\nDB_PORT=%s\nNEW=\"a b\"\n", hostRef, secret("newport", "5433"))
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PushDotenv(client, "dotenv-test", path); err != nil {
+		t.Fatal(err)
+	}
+
+	// References that still match the app's values are written back, stale ones are replaced.
+	content = fmt.Sprintf("OLD=%s\nDB_PORT=%s\nDB_HOST=%s\n", oldRef, portRef, hostRef)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := PullDotenv(client, "dotenv-test", path, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("OLD=%s\nDB_PORT=5432\nDB_HOST=%s\n", oldRef, hostRef)
+	if actual, _ := ioutil.ReadFile(path); string(actual) != expected {
+		t.Errorf("Expected %q, Got %q", expected, actual)
+	}
+}
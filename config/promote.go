@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+// ErrSameApp is returned when promoting an app's config to itself.
+var ErrSameApp = errors.New("cannot promote an app's config to itself")
+
+// PromoteOptions selects what Promote copies.
+//
+// Patterns use the syntax of path.Match, for example "DB_*" or "*_URL".
+type PromoteOptions struct {
+	// Allow lists patterns of keys to promote. If it is empty, all keys are promoted.
+	Allow []string
+	// Deny lists patterns of keys that are never promoted. It takes precedence over Allow.
+	Deny []string
+	// EnvironmentSpecific lists patterns of keys that hold per-environment values, such as
+	// database hosts or hostnames. They are skipped unless IncludeEnvironmentSpecific is set.
+	EnvironmentSpecific []string
+	// IncludeEnvironmentSpecific promotes environment-specific keys that are otherwise allowed.
+	IncludeEnvironmentSpecific bool
+	// Limits also copies the memory and CPU limits of every process type.
+	Limits bool
+	// Healthchecks also copies the healthchecks of every process type.
+	Healthchecks bool
+	// DryRun computes the plan without changing the target app.
+	DryRun bool
+}
+
+// SkippedKey is a key of the source app that Promote did not copy.
+type SkippedKey struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// Reasons a key was not promoted.
+const (
+	SkipNotAllowed          = "not allowed"
+	SkipDenied              = "denied"
+	SkipEnvironmentSpecific = "environment-specific"
+)
+
+// PromotePlan describes the changes made, or to be made in a dry run, by Promote.
+type PromotePlan struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Config is the patch sent to config.Set. It is empty if nothing changes.
+	// It holds unmasked values and is left out of the plan's JSON encoding.
+	Config api.Config `json:"-"`
+	// Diff is the change to the target app's config, with secret values masked.
+	Diff Diff `json:"diff"`
+	// Skipped lists the source app's keys that were not promoted and why.
+	Skipped []SkippedKey `json:"skipped"`
+	// Result is the target app's new config. It is only set if a release was created.
+	// Like Config, it is left out of the plan's JSON encoding.
+	Result *api.Config `json:"-"`
+}
+
+// Promote copies config values, and optionally limits and healthchecks, from one app to
+// another in a single release. Only keys whose value differs in the target app are set;
+// keys missing from the source app are left alone in the target app.
+//
+// This example promotes everything except environment-specific keys from staging to production,
+// after checking the plan:
+//
+//    opts := config.PromoteOptions{
+//        Deny:                []string{"DEBUG"},
+//        EnvironmentSpecific: []string{"DATABASE_URL", "*_HOST"},
+//        DryRun:              true,
+//    }
+//    plan, err := config.Promote(client, "example-staging", "example-production", opts)
+//    if err != nil {
+//        log.Fatal(err)
+//    }
+//    fmt.Print(plan.Diff)
+//
+//    opts.DryRun = false
+//    plan, err = config.Promote(client, "example-staging", "example-production", opts)
+func Promote(c *deis.Client, fromApp string, toApp string, opts PromoteOptions) (PromotePlan, error) {
+	if fromApp == toApp {
+		return PromotePlan{}, ErrSameApp
+	}
+
+	for _, patterns := range [][]string{opts.Allow, opts.Deny, opts.EnvironmentSpecific} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return PromotePlan{}, fmt.Errorf("%s: %q", err, p)
+			}
+		}
+	}
+
+	from, err := List(c, fromApp)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return PromotePlan{}, err
+	}
+
+	to, reqErr := List(c, toApp)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return PromotePlan{}, reqErr
+	}
+
+	plan := PlanPromotion(from, to, opts)
+	plan.From, plan.To = fromApp, toApp
+	plan.Diff.From, plan.Diff.To = fromApp, toApp
+
+	if opts.DryRun || plan.Diff.Empty() {
+		return plan, reqErr
+	}
+
+	result, reqErr := Set(c, toApp, plan.Config)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return plan, reqErr
+	}
+	plan.Result = &result
+
+	return plan, reqErr
+}
+
+// PlanPromotion computes the promotion of from into to without contacting the controller.
+// Invalid patterns in opts never match.
+func PlanPromotion(from api.Config, to api.Config, opts PromoteOptions) PromotePlan {
+	plan := PromotePlan{From: from.App, To: to.App, Skipped: []SkippedKey{}}
+	patch := api.Config{}
+
+	// after is the target config once the patch is applied, used to compute the diff.
+	after := to
+	after.Values = cloneMap(to.Values)
+	after.Memory = cloneMap(to.Memory)
+	after.CPU = cloneMap(to.CPU)
+	after.Healthcheck = make(map[string]*api.Healthchecks, len(to.Healthcheck))
+	for p, h := range to.Healthcheck {
+		after.Healthcheck[p] = h
+	}
+
+	keys := make([]string, 0, len(from.Values))
+	for k := range from.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch {
+		case matchAny(opts.Deny, k):
+			plan.Skipped = append(plan.Skipped, SkippedKey{Key: k, Reason: SkipDenied})
+			continue
+		case len(opts.Allow) > 0 && !matchAny(opts.Allow, k):
+			plan.Skipped = append(plan.Skipped, SkippedKey{Key: k, Reason: SkipNotAllowed})
+			continue
+		case !opts.IncludeEnvironmentSpecific && matchAny(opts.EnvironmentSpecific, k):
+			plan.Skipped = append(plan.Skipped, SkippedKey{Key: k, Reason: SkipEnvironmentSpecific})
+			continue
+		}
+
+		v := from.Values[k]
+		if current, ok := to.Values[k]; ok && valueString(current) == valueString(v) {
+			continue
+		}
+		patch.Values = setKey(patch.Values, k, v)
+		after.Values[k] = v
+	}
+
+	if opts.Limits {
+		for k, v := range from.Memory {
+			if current, ok := to.Memory[k]; !ok || valueString(current) != valueString(v) {
+				patch.Memory = setKey(patch.Memory, k, v)
+				after.Memory[k] = v
+			}
+		}
+		for k, v := range from.CPU {
+			if current, ok := to.CPU[k]; !ok || valueString(current) != valueString(v) {
+				patch.CPU = setKey(patch.CPU, k, v)
+				after.CPU[k] = v
+			}
+		}
+	}
+
+	if opts.Healthchecks && len(from.Healthcheck) > 0 {
+		fromChecks := flatten(from, SectionHealthcheck)
+		toChecks := flatten(to, SectionHealthcheck)
+		for procType, checks := range from.Healthcheck {
+			changed := false
+			if checks != nil {
+				for probe := range *checks {
+					if fromChecks[procType+"/"+probe] != toChecks[procType+"/"+probe] {
+						changed = true
+					}
+				}
+			}
+			if !changed {
+				continue
+			}
+			if patch.Healthcheck == nil {
+				patch.Healthcheck = make(map[string]*api.Healthchecks)
+			}
+			patch.Healthcheck[procType] = checks
+			after.Healthcheck[procType] = checks
+		}
+	}
+
+	plan.Config = patch
+	plan.Diff = Compare(to, after, DiffOptions{})
+	return plan
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func setKey(m map[string]interface{}, k string, v interface{}) map[string]interface{} {
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	m[k] = v
+	return m
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+const promoteExpected string = `{"values":{"DB_PASSWORD":"staging-secret"},"memory":{"web":"1G"}}`
+
+type promoteHTTPServer struct {
+	mu    sync.Mutex
+	posts int
+}
+
+func (p *promoteHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/production/config/" && req.Method == "POST" {
+		p.mu.Lock()
+		p.posts++
+		p.mu.Unlock()
+
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			fmt.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+		}
+
+		if string(body) != promoteExpected {
+			fmt.Printf("Expected '%s', Got '%s'\n", promoteExpected, body)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(productionConfigFixture))
+		return
+	}
+
+	diffHTTPServer{}.ServeHTTP(res, req)
+}
+
+var promoteOptions = PromoteOptions{
+	Deny:                []string{"DEBUG"},
+	EnvironmentSpecific: []string{"*_HOST"},
+	Limits:              true,
+}
+
+func TestPromote(t *testing.T) {
+	t.Parallel()
+
+	handler := &promoteHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := promoteOptions
+	opts.DryRun = true
+
+	plan, err := Promote(deis, "staging", "production", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handler.posts != 0 {
+		t.Errorf("Expected no release in a dry run, Got %d", handler.posts)
+	}
+
+	expectedSkipped := []SkippedKey{
+		{Key: "DB_HOST", Reason: SkipEnvironmentSpecific},
+		{Key: "DEBUG", Reason: SkipDenied},
+	}
+	if !reflect.DeepEqual(expectedSkipped, plan.Skipped) {
+		t.Errorf("Expected %v, Got %v", expectedSkipped, plan.Skipped)
+	}
+
+	expectedChanges := []Change{
+		{Section: SectionValues, Key: "DB_PASSWORD", Type: Changed, Old: Mask, New: Mask, Masked: true},
+		{Section: SectionMemory, Key: "web", Type: Changed, Old: "2G", New: "1G"},
+	}
+	if !reflect.DeepEqual(expectedChanges, plan.Diff.Changes) {
+		t.Errorf("Expected %v, Got %v", expectedChanges, plan.Diff.Changes)
+	}
+
+	if plan.Result != nil {
+		t.Errorf("Expected no result in a dry run, Got %v", plan.Result)
+	}
+
+	plan, err = Promote(deis, "staging", "production", promoteOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handler.posts != 1 {
+		t.Errorf("Expected 1 release, Got %d", handler.posts)
+	}
+
+	if plan.Result == nil || plan.Result.App != "production" {
+		t.Errorf("Expected the new production config, Got %v", plan.Result)
+	}
+}
+
+func TestPromoteErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Promote(nil, "staging", "staging", PromoteOptions{}); err != ErrSameApp {
+		t.Errorf("Expected %v, Got %v", ErrSameApp, err)
+	}
+
+	if _, err := Promote(nil, "staging", "production", PromoteOptions{Allow: []string{"["}}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestPlanPromotion(t *testing.T) {
+	t.Parallel()
+
+	probe := &api.Healthcheck{PeriodSeconds: 10, TCPSocket: &api.TCPSocketProbe{Port: 5000}}
+	from := api.Config{
+		Values: map[string]interface{}{"A_URL": "a", "B_URL": "b", "OTHER": "c", "HOST": "h"},
+		Healthcheck: map[string]*api.Healthchecks{
+			"web": {"livenessProbe": probe},
+		},
+	}
+	to := api.Config{
+		Values: map[string]interface{}{"A_URL": "a", "HOST": "x"},
+	}
+
+	plan := PlanPromotion(from, to, PromoteOptions{
+		Allow:                      []string{"*_URL", "HOST"},
+		EnvironmentSpecific:        []string{"HOST"},
+		IncludeEnvironmentSpecific: true,
+		Healthchecks:               true,
+	})
+
+	expected := api.Config{
+		Values:      map[string]interface{}{"B_URL": "b", "HOST": "h"},
+		Healthcheck: map[string]*api.Healthchecks{"web": {"livenessProbe": probe}},
+	}
+	if !reflect.DeepEqual(expected, plan.Config) {
+		t.Errorf("Expected %v, Got %v", expected, plan.Config)
+	}
+
+	expectedSkipped := []SkippedKey{{Key: "OTHER", Reason: SkipNotAllowed}}
+	if !reflect.DeepEqual(expectedSkipped, plan.Skipped) {
+		t.Errorf("Expected %v, Got %v", expectedSkipped, plan.Skipped)
+	}
+
+	if len(to.Values) != 2 || to.Values["HOST"] != "x" {
+		t.Errorf("Expected the target config to be unchanged, Got %v", to.Values)
+	}
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+var (
+	// ErrInvalidTemplate is returned when a value has a malformed ${...} reference.
+	ErrInvalidTemplate = errors.New("invalid config template")
+	// ErrUndefinedVariable is returned when a value references a key that is not defined.
+	ErrUndefinedVariable = errors.New("undefined variable")
+	// ErrVariableCycle is returned when values reference each other in a cycle.
+	ErrVariableCycle = errors.New("variable reference cycle")
+)
+
+// VariableSet is a set of shared variables that config values can reference, such as the
+// database settings common to several apps.
+type VariableSet map[string]string
+
+// LoadVariableSet reads a variable set from a dotenv file. See ParseDotenv.
+func LoadVariableSet(path string) (VariableSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pairs, err := ParseDotenv(f)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(VariableSet, len(pairs))
+	for _, pair := range pairs {
+		set[pair.Name] = pair.Value
+	}
+	return set, nil
+}
+
+// Expand returns a copy of config values with references to other keys expanded.
+// A reference is written ${NAME}, as in DATABASE_URL=postgres://${DB_HOST}:${DB_PORT}/app,
+// and $$ is a literal $. A $ that is not followed by { or $ is kept as it is.
+//
+// References are looked up in values first, then in sets in order, and are expanded
+// recursively. Expand returns ErrUndefinedVariable if a reference is not defined anywhere and
+// ErrVariableCycle if values reference each other in a cycle. Values that are not strings,
+// including nil values that unset keys, are copied unchanged.
+func Expand(values map[string]interface{}, sets ...VariableSet) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	e := expander{values: values, sets: sets, done: make(map[string]string), active: make(map[string]bool)}
+
+	expanded := make(map[string]interface{}, len(values))
+	for _, key := range sortedValueKeys(values) {
+		if _, ok := values[key].(string); !ok {
+			expanded[key] = values[key]
+			continue
+		}
+		v, err := e.lookup(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		expanded[key] = v
+	}
+	return expanded, nil
+}
+
+// References returns the names referenced by a value, in order of first appearance.
+func References(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	_, err := expandString(value, func(name string) (string, error) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return "", nil
+	})
+	return names, err
+}
+
+// SetExpanded expands the config's values with Expand and sets them on the app.
+// References that are not defined in the config or sets are looked up in the app's current
+// config values, so values can be derived from keys that are already set.
+func SetExpanded(c *deis.Client, app string, config api.Config, sets ...VariableSet) (api.Config, error) {
+	current, err := List(c, app)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Config{}, err
+	}
+
+	currentSet := make(VariableSet, len(current.Values))
+	for k, v := range current.Values {
+		if v != nil {
+			currentSet[k] = valueString(v)
+		}
+	}
+
+	values, err := Expand(config.Values, append(sets, currentSet)...)
+	if err != nil {
+		return api.Config{}, err
+	}
+	config.Values = values
+
+	return Set(c, app, config)
+}
+
+// expander expands values, remembering expanded variables and detecting cycles.
+type expander struct {
+	values map[string]interface{}
+	sets   []VariableSet
+	done   map[string]string
+	active map[string]bool
+	stack  []string
+}
+
+func (e *expander) lookup(name string) (string, error) {
+	if v, ok := e.done[name]; ok {
+		return v, nil
+	}
+
+	raw, ok := e.raw(name)
+	if !ok {
+		return "", fmt.Errorf("%w: ${%s}", ErrUndefinedVariable, name)
+	}
+
+	if e.active[name] {
+		cycle := append([]string{}, e.stack[indexOf(e.stack, name):]...)
+		return "", fmt.Errorf("%w: %s -> %s", ErrVariableCycle, strings.Join(cycle, " -> "), name)
+	}
+
+	e.active[name] = true
+	e.stack = append(e.stack, name)
+	v, err := expandString(raw, e.lookup)
+	e.stack = e.stack[:len(e.stack)-1]
+	delete(e.active, name)
+	if err != nil {
+		return "", err
+	}
+
+	e.done[name] = v
+	return v, nil
+}
+
+// raw returns the unexpanded value of a variable.
+func (e *expander) raw(name string) (string, bool) {
+	if v, ok := e.values[name]; ok && v != nil {
+		return valueString(v), true
+	}
+	for _, set := range e.sets {
+		if v, ok := set[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// expandString replaces the ${NAME} references of s with the values returned by lookup.
+func expandString(s string, lookup func(name string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated reference in %q", ErrInvalidTemplate, s)
+			}
+			name := s[i+2 : i+2+end]
+			if !keyRegex.MatchString(name) {
+				return "", fmt.Errorf("%w: invalid variable name %q", ErrInvalidTemplate, name)
+			}
+			v, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i += end + 2
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func sortedValueKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+const templateConfigFixture string = `
+{
+    "owner": "test",
+    "app": "template-test",
+    "values": {
+      "DB_HOST": "db.example.com"
+    },
+    "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+}
+`
+
+const templateSetExpected string = `{"values":{"DATABASE_URL":"postgres://db.example.com:5432/app"}}`
+
+type templateHTTPServer struct{}
+
+func (templateHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path == "/v2/apps/template-test/config/" && req.Method == "GET" {
+		res.Write([]byte(templateConfigFixture))
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/template-test/config/" && req.Method == "POST" {
+		body, err := ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			fmt.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+		}
+
+		if string(body) != templateSetExpected {
+			fmt.Printf("Expected '%s', Got '%s'\n", templateSetExpected, body)
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write(nil)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(templateConfigFixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestExpand(t *testing.T) {
+	t.Parallel()
+
+	base := VariableSet{"DB_HOST": "base-db", "DB_PORT": "5432", "DB_NAME": "${APP}_production"}
+	values := map[string]interface{}{
+		"APP":          "shop",
+		"DB_HOST":      "shop-db",
+		"DATABASE_URL": "postgres://${DB_HOST}:${DB_PORT}/${DB_NAME}",
+		"PRICE":        "$$5 or $5",
+		"WORKERS":      4,
+		"OLD":          nil,
+	}
+
+	expected := map[string]interface{}{
+		"APP":          "shop",
+		"DB_HOST":      "shop-db",
+		"DATABASE_URL": "postgres://shop-db:5432/shop_production",
+		"PRICE":        "$5 or $5",
+		"WORKERS":      4,
+		"OLD":          nil,
+	}
+
+	actual, err := Expand(values, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+	if values["DATABASE_URL"] != "postgres://${DB_HOST}:${DB_PORT}/${DB_NAME}" {
+		t.Error("Expected the values not to be modified")
+	}
+
+	tests := []struct {
+		Values   map[string]interface{}
+		Expected error
+	}{
+		{map[string]interface{}{"URL": "${MISSING}"}, ErrUndefinedVariable},
+		{map[string]interface{}{"URL": "${OLD}", "OLD": nil}, ErrUndefinedVariable},
+		{map[string]interface{}{"A": "${B}", "B": "${C}", "C": "${A}"}, ErrVariableCycle},
+		{map[string]interface{}{"A": "${A}"}, ErrVariableCycle},
+		{map[string]interface{}{"A": "${B"}, ErrInvalidTemplate},
+		{map[string]interface{}{"A": "${1B}"}, ErrInvalidTemplate},
+	}
+
+	for _, test := range tests {
+		if _, err := Expand(test.Values); !errors.Is(err, test.Expected) {
+			t.Errorf("%v: Expected %v, Got %v", test.Values, test.Expected, err)
+		}
+	}
+
+	_, err = Expand(map[string]interface{}{"A": "${B}", "B": "${C}", "C": "${B}"})
+	if err == nil || err.Error() != "A: variable reference cycle: B -> C -> B" {
+		t.Errorf("Expected the cycle to be reported, Got %v", err)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	actual, err := References("${B}$$${A}-${B}$C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"B", "A"}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestLoadVariableSet(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "shared.env")
+	if err := ioutil.WriteFile(path, []byte("# shared\nDB_PORT=5432\nDB_NAME='${APP}'\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := LoadVariableSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (VariableSet{"DB_PORT": "5432", "DB_NAME": "${APP}"}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestSetExpanded(t *testing.T) {
+	t.Parallel()
+
+	handler := templateHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := VariableSet{"DB_PORT": "5432"}
+	config := api.Config{Values: map[string]interface{}{"DATABASE_URL": "postgres://${DB_HOST}:${DB_PORT}/app"}}
+
+	if _, err := SetExpanded(client, "template-test", config, shared); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Values["DATABASE_URL"] = "postgres://${DB_USER}@${DB_HOST}/app"
+	if _, err := SetExpanded(client, "template-test", config, shared); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("Expected %v, Got %v", ErrUndefinedVariable, err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package deploy provides an end-to-end deploy of an image to an app: it creates a build, waits
+// for the new release to run, optionally checks it, and rolls back automatically on failure.
+//
+// This example deploys an image and rolls back if the app doesn't answer within five minutes:
+//
+//    report, err := deploy.Deploy(ctx, <client>, "appname", "registry.example.com/app:v2", deploy.Options{
+//    	Timeout: 5 * time.Minute,
+//    	SmokeCheck: func(ctx context.Context, release api.Release) error {
+//    		res, err := http.Get("https://appname.example.com/health")
+//    		if err != nil {
+//    			return err
+//    		}
+//    		defer res.Body.Close()
+//    		if res.StatusCode != http.StatusOK {
+//    			return fmt.Errorf("health check returned %s", res.Status)
+//    		}
+//    		return nil
+//    	},
+//    })
+//    if err != nil {
+//    	log.Fatalf("%s: %v", report.Status, err)
+//    }
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/builds"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/ps"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/releases"
+)
+
+// Default durations used when Options leaves them unset.
+const (
+	DefaultTimeout         = 10 * time.Minute
+	DefaultRollbackTimeout = 5 * time.Minute
+	DefaultPollInterval    = 5 * time.Second
+)
+
+// ErrSmokeCheck is returned when the smoke check of a release fails.
+var ErrSmokeCheck = errors.New("smoke check failed")
+
+// Status is the outcome of a deploy.
+type Status string
+
+const (
+	// Succeeded deploys run the new release on every pod and passed the smoke check.
+	Succeeded Status = "succeeded"
+	// RolledBack deploys failed and the app was rolled back to its previous release.
+	RolledBack Status = "rolled-back"
+	// Failed deploys failed and the app was not rolled back, because no release was created,
+	// rollback is disabled or the rollback failed.
+	Failed Status = "failed"
+)
+
+// Names of the steps of a deploy, in order.
+const (
+	StepBuild        = "build"
+	StepRelease      = "release"
+	StepPods         = "pods"
+	StepSmokeCheck   = "smoke-check"
+	StepRollback     = "rollback"
+	StepRollbackPods = "rollback-pods"
+)
+
+// Options controls a deploy.
+type Options struct {
+	// Procfile defines the process types of the build, see builds.New.
+	Procfile map[string]string
+	// Timeout bounds the time from the build to all pods running the new release, smoke
+	// check included. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// RollbackTimeout bounds the wait for pods to run the previous release after a rollback.
+	// Defaults to DefaultRollbackTimeout.
+	RollbackTimeout time.Duration
+	// PollInterval is the interval between two listings of releases or pods.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// SmokeCheck, if set, is called once all pods run the new release. If it returns an error,
+	// the deploy fails.
+	SmokeCheck func(ctx context.Context, release api.Release) error
+	// DisableRollback leaves the app on the new release if the deploy fails.
+	DisableRollback bool
+}
+
+// Step is the record of a step of a deploy.
+type Step struct {
+	Name     string    `json:"name"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Error is the reason the step failed, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Report describes a deploy, for logs and pipeline output.
+type Report struct {
+	App   string `json:"app"`
+	Image string `json:"image"`
+	// Build is the created build, empty if it could not be created.
+	Build api.Build `json:"build"`
+	// PreviousVersion is the release version before the deploy, 0 if the app had none.
+	PreviousVersion int `json:"previous_version"`
+	// Release is the release created for the build, empty if there was none.
+	Release api.Release `json:"release"`
+	// RollbackVersion is the release version created by the rollback, 0 if there was none.
+	RollbackVersion int    `json:"rollback_version,omitempty"`
+	Status          Status `json:"status"`
+	Steps           []Step `json:"steps"`
+	// Pods are the app's pods when the deploy finished.
+	Pods     api.PodsList `json:"pods"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	// Error is the reason the deploy failed, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Deploy creates a build of image for an app, waits for the controller to create its release
+// and for every pod to run that release and be up, then runs the smoke check if there is one.
+//
+// If any of this fails or times out after a release was created, the app is rolled back to
+// the release it had before the deploy with releases.Rollback, unless rollback is disabled,
+// and Deploy waits for the pods to run it again. The report describes each step either way.
+// The returned error is the reason the deploy failed, nil if it succeeded.
+func Deploy(ctx context.Context, c *deis.Client, app string, image string, opts Options) (Report, error) {
+	r := &Report{App: app, Image: image, Started: time.Now(), Steps: []Step{}}
+
+	err := r.deploy(ctx, c, opts)
+	if err == nil {
+		r.Status = Succeeded
+	} else {
+		r.Error = err.Error()
+		r.Status = Failed
+		if r.Release.Version > r.PreviousVersion && r.PreviousVersion > 0 && !opts.DisableRollback {
+			if r.rollback(c, opts) == nil {
+				r.Status = RolledBack
+			}
+		}
+	}
+
+	r.Finished = time.Now()
+	return *r, err
+}
+
+func (r *Report) deploy(ctx context.Context, c *deis.Client, opts Options) error {
+	previous, err := latest(c, r.App)
+	if err != nil {
+		return err
+	}
+	r.PreviousVersion = previous.Version
+
+	ctx, cancel := context.WithTimeout(ctx, duration(opts.Timeout, DefaultTimeout))
+	defer cancel()
+
+	if err := r.step(StepBuild, func() error {
+		build, err := builds.New(c, r.App, r.Image, opts.Procfile)
+		r.Build = build
+		return err
+	}); err != nil {
+		// The controller may have created the release and failed to deploy it, so look for it
+		// to be able to roll it back.
+		if release, latestErr := latest(c, r.App); latestErr == nil && release.Version > r.PreviousVersion {
+			r.Release = release
+		}
+		return err
+	}
+
+	if err := r.step(StepRelease, func() error {
+		release, err := waitForRelease(ctx, c, r.App, r.PreviousVersion, r.Build.UUID, interval(opts))
+		r.Release = release
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := r.step(StepPods, func() error {
+		pods, err := ps.WaitForVersion(ctx, c, r.App, r.Release.Version, interval(opts))
+		r.Pods = pods
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if opts.SmokeCheck == nil {
+		return nil
+	}
+	return r.step(StepSmokeCheck, func() error {
+		if err := opts.SmokeCheck(ctx, r.Release); err != nil {
+			return fmt.Errorf("%w: %v", ErrSmokeCheck, err)
+		}
+		return nil
+	})
+}
+
+func (r *Report) rollback(c *deis.Client, opts Options) error {
+	err := r.step(StepRollback, func() error {
+		version, err := releases.Rollback(c, r.App, r.PreviousVersion)
+		r.RollbackVersion = version
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration(opts.RollbackTimeout, DefaultRollbackTimeout))
+	defer cancel()
+
+	// A rollback whose pods are slow to start is still a rollback: the error is only reported.
+	r.step(StepRollbackPods, func() error {
+		pods, err := ps.WaitForVersion(ctx, c, r.App, r.RollbackVersion, interval(opts))
+		r.Pods = pods
+		return err
+	})
+	return nil
+}
+
+// step runs f and records it in the report.
+func (r *Report) step(name string, f func() error) error {
+	s := Step{Name: name, Started: time.Now()}
+	err := f()
+	s.Finished = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	r.Steps = append(r.Steps, s)
+	return err
+}
+
+// latest returns an app's latest release, or an empty release if it has none.
+func latest(c *deis.Client, app string) (api.Release, error) {
+	list, _, err := releases.List(c, app, 1)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return api.Release{}, err
+	}
+	if len(list) == 0 {
+		return api.Release{}, nil
+	}
+	return list[0], nil
+}
+
+// waitForRelease polls an app's releases until one newer than previous is created for the build.
+// Releases of other builds, and those without a build such as config changes made during the
+// deploy, are not the deploy's release.
+func waitForRelease(ctx context.Context, c *deis.Client, app string, previous int, build string,
+	interval time.Duration) (api.Release, error) {
+	if build == "" {
+		return api.Release{}, errors.New("the controller did not return the uuid of the build")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		release, err := latest(c, app)
+		if err != nil {
+			return api.Release{}, err
+		}
+		if release.Version > previous && release.Build == build {
+			return release, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return api.Release{}, fmt.Errorf("waiting for the release of build %s: %w", build, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func interval(opts Options) time.Duration {
+	return duration(opts.PollInterval, DefaultPollInterval)
+}
+
+func duration(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
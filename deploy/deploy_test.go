@@ -0,0 +1,274 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+const buildFixture string = `
+{
+    "app": "example-go",
+    "created": "2014-01-01T00:00:00UTC",
+    "image": "deis/example-go:v2",
+    "owner": "test",
+    "procfile": {},
+    "updated": "2014-01-01T00:00:00UTC",
+    "uuid": "b2b2b2b2-4a72-4f94-a10c-d2a3741cdf75"
+}`
+
+const deployAppFixture string = `{"id": "example-go", "owner": "test", "procfile_structure": {"web": "example-go"}}`
+
+// fakeController simulates an app on release v1 whose pods move to each new release after
+// a few pod listings.
+type fakeController struct {
+	mu sync.Mutex
+	// version is the latest release version.
+	version int
+	// running is the release version the pods run.
+	running int
+	// podsUntilUp is the number of pod listings before the pods run the latest release.
+	podsUntilUp int
+	// stuck keeps the pods from ever running a new release.
+	stuck bool
+	// failBuild makes the build request fail after creating the release.
+	failBuild bool
+	// configChanges is the number of release listings after the build that show a newer
+	// release without a build, as made by a concurrent config change.
+	configChanges int
+	requests  []string
+}
+
+func (f *fakeController) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+	f.requests = append(f.requests, req.Method+" "+req.URL.Path)
+
+	switch {
+	case req.URL.Path == "/v2/apps/example-go/releases/" && req.Method == "GET":
+		build := "null"
+		if f.version > 1 && f.configChanges > 0 {
+			f.configChanges--
+		} else if f.version > 1 {
+			build = `"b2b2b2b2-4a72-4f94-a10c-d2a3741cdf75"`
+		}
+		fmt.Fprintf(res, `{"count": %d, "next": null, "previous": null, "results": [
+			{"app": "example-go", "build": %s, "config": "c1", "owner": "test", "summary": "test deployed",
+			 "created": "2014-01-01T00:00:00UTC", "updated": "2014-01-01T00:00:00UTC", "uuid": "u%d", "version": %d}]}`,
+			f.version, build, f.version, f.version)
+
+	case req.URL.Path == "/v2/apps/example-go/builds/" && req.Method == "POST":
+		f.version++
+		if f.failBuild {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte(`{"detail": "deploy failed"}`))
+			return
+		}
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(buildFixture))
+
+	case req.URL.Path == "/v2/apps/example-go/releases/rollback/" && req.Method == "POST":
+		body, _ := ioutil.ReadAll(req.Body)
+		if string(body) != `{"version":1}` {
+			fmt.Printf("Expected a rollback to v1, Got '%s'\n", body)
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.version++
+		f.stuck = false
+		res.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(res, `{"version": %d}`, f.version)
+
+	case req.URL.Path == "/v2/apps/example-go/pods/" && req.Method == "GET":
+		pods := []string{fmt.Sprintf(`{"release": "v%d", "type": "web", "name": "web-%d", "state": "up", "started": "2016-02-13T00:47:52"}`, f.running, f.running)}
+		if f.running != f.version && !f.stuck {
+			if f.podsUntilUp == 0 {
+				f.running = f.version
+				pods = []string{fmt.Sprintf(`{"release": "v%d", "type": "web", "name": "web-%d", "state": "up", "started": "2016-02-13T00:47:52"}`, f.running, f.running)}
+			} else {
+				f.podsUntilUp--
+				pods = append(pods, fmt.Sprintf(`{"release": "v%d", "type": "web", "name": "web-%d", "state": "starting", "started": "2016-02-13T00:47:52"}`, f.version, f.version))
+			}
+		}
+		fmt.Fprintf(res, `{"count": %d, "next": null, "previous": null, "results": [%s]}`, len(pods), strings.Join(pods, ","))
+
+	case req.URL.Path == "/v2/apps/example-go/" && req.Method == "GET":
+		res.Write([]byte(deployAppFixture))
+
+	default:
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+	}
+}
+
+func newFakeController(t *testing.T) (*fakeController, *deis.Client, func()) {
+	f := &fakeController{version: 1, running: 1, podsUntilUp: 2}
+	server := httptest.NewServer(f)
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client, server.Close
+}
+
+func stepNames(steps []Step) []string {
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.Name
+		if s.Error != "" {
+			names[i] += "!"
+		}
+	}
+	return names
+}
+
+func TestDeploy(t *testing.T) {
+	t.Parallel()
+
+	_, client, stop := newFakeController(t)
+	defer stop()
+
+	checked := 0
+	report, err := Deploy(context.Background(), client, "example-go", "deis/example-go:v2", Options{
+		PollInterval: time.Millisecond,
+		SmokeCheck: func(ctx context.Context, release api.Release) error {
+			checked = release.Version
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != Succeeded || report.PreviousVersion != 1 || report.Release.Version != 2 || checked != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if report.Build.UUID != "b2b2b2b2-4a72-4f94-a10c-d2a3741cdf75" || len(report.Pods) != 1 || report.Pods[0].Release != "v2" {
+		t.Errorf("Unexpected build or pods %+v %+v", report.Build, report.Pods)
+	}
+
+	expected := []string{StepBuild, StepRelease, StepPods, StepSmokeCheck}
+	if actual := stepNames(report.Steps); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestDeploySmokeCheckRollback(t *testing.T) {
+	t.Parallel()
+
+	_, client, stop := newFakeController(t)
+	defer stop()
+
+	report, err := Deploy(context.Background(), client, "example-go", "deis/example-go:v2", Options{
+		PollInterval: time.Millisecond,
+		SmokeCheck: func(ctx context.Context, release api.Release) error {
+			return errors.New("500 Internal Server Error")
+		},
+	})
+	if !errors.Is(err, ErrSmokeCheck) {
+		t.Fatalf("Expected %v, Got %v", ErrSmokeCheck, err)
+	}
+
+	if report.Status != RolledBack || report.RollbackVersion != 3 || report.Error == "" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.Pods) != 1 || report.Pods[0].Release != "v3" {
+		t.Errorf("Expected pods on v3, Got %+v", report.Pods)
+	}
+
+	expected := []string{StepBuild, StepRelease, StepPods, StepSmokeCheck + "!", StepRollback, StepRollbackPods}
+	if actual := stepNames(report.Steps); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestDeployConcurrentConfigChange(t *testing.T) {
+	t.Parallel()
+
+	f, client, stop := newFakeController(t)
+	defer stop()
+	f.configChanges = 2
+
+	report, err := Deploy(context.Background(), client, "example-go", "deis/example-go:v2", Options{
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Release.Build != report.Build.UUID || f.configChanges != 0 {
+		t.Errorf("Expected the release of build %s, Got %+v", report.Build.UUID, report.Release)
+	}
+}
+
+func TestDeployTimeout(t *testing.T) {
+	t.Parallel()
+
+	f, client, stop := newFakeController(t)
+	defer stop()
+	f.stuck = true
+
+	report, err := Deploy(context.Background(), client, "example-go", "deis/example-go:v2", Options{
+		PollInterval:    time.Millisecond,
+		Timeout:         20 * time.Millisecond,
+		DisableRollback: true,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, Got %v", context.DeadlineExceeded, err)
+	}
+	if !strings.Contains(err.Error(), "web-1 v1 up") {
+		t.Errorf("Expected the pods that are not ready in the error, Got %v", err)
+	}
+
+	if report.Status != Failed || report.RollbackVersion != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	expected := []string{StepBuild, StepRelease, StepPods + "!"}
+	if actual := stepNames(report.Steps); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestDeployBuildFailure(t *testing.T) {
+	t.Parallel()
+
+	f, client, stop := newFakeController(t)
+	defer stop()
+	f.failBuild = true
+
+	report, err := Deploy(context.Background(), client, "example-go", "deis/example-go:v2", Options{
+		PollInterval: time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("Expected the deploy to fail")
+	}
+
+	// The failed build created v2, which is rolled back.
+	if report.Status != RolledBack || report.Release.Version != 2 || report.RollbackVersion != 3 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	expected := []string{StepBuild + "!", StepRollback, StepRollbackPods}
+	if actual := stepNames(report.Steps); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	// Invalid images fail before anything is created.
+	report, err = Deploy(context.Background(), client, "example-go", "Deis/Example-Go", Options{})
+	if !errors.Is(err, deis.ErrInvalidImage) || report.Status != Failed {
+		t.Errorf("Expected %v and no rollback, Got %v %+v", deis.ErrInvalidImage, err, report)
+	}
+}
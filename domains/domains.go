@@ -7,6 +7,7 @@ import (
 
 	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
 	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/apps"
 )
 
 // List domains registered with an app.
@@ -26,6 +27,50 @@ func List(c *deis.Client, appID string, results int) (api.Domains, int, error) {
 	return domains, count, reqErr
 }
 
+// ListAll lists all of an app's domains, fetching every page.
+func ListAll(c *deis.Client, appID string) (api.Domains, error) {
+	u := fmt.Sprintf("/v2/apps/%s/domains/", appID)
+	body, reqErr := c.LimitedRequestAll(u)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	var domains api.Domains
+	if err := json.Unmarshal([]byte(body), &domains); err != nil {
+		return nil, err
+	}
+	return domains, reqErr
+}
+
+// ListApps lists all the domains of several apps, or of every app the user can access if no
+// app is given. Each domain has the app it belongs to.
+func ListApps(c *deis.Client, appIDs ...string) (api.Domains, error) {
+	var mismatch error
+	if len(appIDs) == 0 {
+		list, err := apps.ListAll(c)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return nil, err
+		}
+		mismatch = err
+		for _, app := range list {
+			appIDs = append(appIDs, app.ID)
+		}
+	}
+
+	domains := api.Domains{}
+	for _, appID := range appIDs {
+		appDomains, err := ListAll(c, appID)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return nil, err
+		}
+		if err != nil {
+			mismatch = err
+		}
+		domains = append(domains, appDomains...)
+	}
+	return domains, mismatch
+}
+
 // New adds a domain to an app.
 func New(c *deis.Client, appID string, domain string) (api.Domain, error) {
 	u := fmt.Sprintf("/v2/apps/%s/domains/", appID)
@@ -49,6 +49,11 @@ func (fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if req.URL.Path == "/v2/apps/" && req.Method == "GET" {
+		res.Write([]byte(`{"count": 1, "next": null, "previous": null, "results": [{"id": "example-go"}]}`))
+		return
+	}
+
 	if req.URL.Path == "/v2/apps/example-go/domains/" && req.Method == "POST" {
 		body, err := ioutil.ReadAll(req.Body)
 
@@ -161,3 +166,27 @@ func TestDomainsRemove(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDomainsListApps(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, appIDs := range [][]string{nil, {"example-go"}} {
+		actual, err := ListApps(client, appIDs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(actual) != 1 || actual[0].App != "example-go" || actual[0].Domain != "example.example.com" {
+			t.Errorf("%v: Unexpected domains %v", appIDs, actual)
+		}
+	}
+}
@@ -104,6 +104,60 @@ func (e ErrNotFound) Error() string {
 	return e.errorMsg
 }
 
+// ErrModified is returned by compare-and-set updates when a resource was changed by
+// someone else since the caller read it.
+type ErrModified struct {
+	// Resource describes what was modified, such as "config" or "settings".
+	Resource string
+	// Expected is the UUID the caller read.
+	Expected string
+	// Actual is the UUID the resource has now.
+	Actual string
+}
+
+func (e ErrModified) Error() string {
+	return fmt.Sprintf("%s was modified since it was read (expected uuid %s, found %s)",
+		e.Resource, e.Expected, e.Actual)
+}
+
+// IsErrModified returns true if err is an ErrModified, false otherwise
+func IsErrModified(err error) bool {
+	_, ok := err.(ErrModified)
+	return ok
+}
+
+// CompareAndSet updates a resource identified by a UUID only if it has not been modified since
+// the caller read it as uuid. It reads the resource and its current UUID with read, and if the
+// UUID is uuid, calls set with patch. Otherwise it returns an ErrModified for resource, or if
+// merge is not nil, calls merge to rebase the patch onto the current resource and tries again,
+// up to retries times.
+//
+// The controller does not support conditional writes, so this narrows, but does not close,
+// the window in which a concurrent change can be overwritten.
+func CompareAndSet[T any](resource string, uuid string, patch T, read func() (T, string, error),
+	set func(T) (T, error), merge func(current T, patch T) (T, error), retries int) (T, error) {
+	var zero T
+	for attempt := 0; ; attempt++ {
+		current, currentUUID, err := read()
+		if err != nil && !IsErrAPIMismatch(err) {
+			return zero, err
+		}
+
+		if currentUUID == uuid {
+			return set(patch)
+		}
+
+		if merge == nil || attempt >= retries {
+			return zero, ErrModified{Resource: resource, Expected: uuid, Actual: currentUUID}
+		}
+
+		if patch, err = merge(current, patch); err != nil {
+			return zero, err
+		}
+		uuid = currentUUID
+	}
+}
+
 // checkForErrors tries to match up an API error with an predefined error in the SDK.
 func checkForErrors(res *http.Response) error {
 	if res.StatusCode >= 200 && res.StatusCode < 400 {
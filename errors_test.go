@@ -298,3 +298,50 @@ newline"}`),
 		}
 	}
 }
+
+func TestErrModified(t *testing.T) {
+	err := ErrModified{Resource: "config", Expected: "a", Actual: "b"}
+
+	expected := "config was modified since it was read (expected uuid a, found b)"
+	if err.Error() != expected {
+		t.Errorf(failureMessage, expected, err)
+	}
+
+	if !IsErrModified(err) {
+		t.Errorf("Expected IsErrModified to be true for %v", err)
+	}
+
+	if IsErrModified(ErrConflict) {
+		t.Errorf("Expected IsErrModified to be false for %v", ErrConflict)
+	}
+}
+
+func TestCompareAndSet(t *testing.T) {
+	uuids := []string{"b", "c"}
+	reads := 0
+	read := func() (string, string, error) {
+		uuid := uuids[reads]
+		reads++
+		return "value-" + uuid, uuid, nil
+	}
+	set := func(patch string) (string, error) { return patch, nil }
+	merge := func(current string, patch string) (string, error) { return patch + "+" + current, nil }
+
+	actual, err := CompareAndSet("config", "a", "patch", read, set, merge, 1)
+	expected := ErrModified{Resource: "config", Expected: "b", Actual: "c"}
+	if err != expected || actual != "" {
+		t.Errorf(failureMessage, expected, err)
+	}
+
+	reads = 0
+	uuids = []string{"b", "b"}
+	actual, err = CompareAndSet("config", "a", "patch", read, set, merge, 1)
+	if err != nil || actual != "patch+value-b" {
+		t.Errorf("Expected the patch to be merged once, Got %q %v", actual, err)
+	}
+
+	reads = 0
+	if _, err := CompareAndSet("config", "a", "patch", read, set, nil, 1); !IsErrModified(err) || reads != 1 {
+		t.Errorf("Expected an ErrModified without merging, Got %v after %d reads", err, reads)
+	}
+}
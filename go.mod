@@ -2,11 +2,15 @@ module github.com/trilogy-group/devgraph-eyk-controller-sdk-go
 
 go 1.18
 
-require github.com/goware/urlx v0.0.0-20160722204212-8bb4a2e4339f
+require (
+	github.com/goware/urlx v0.0.0-20160722204212-8bb4a2e4339f
+	golang.org/x/crypto v0.23.0
+	software.sslmate.com/src/go-pkcs12 v0.4.0
+)
 
 require (
 	github.com/PuerkitoBio/purell v1.1.0 // indirect
 	github.com/PuerkitoBio/urlesc v0.0.0-20160726150825-5bd2802263f2 // indirect
-	golang.org/x/net v0.0.0-20150927182833-c2528b2dd835 // indirect
-	golang.org/x/text v0.0.0-20161209224335-47a200a05c8b // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
 )
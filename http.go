@@ -103,6 +103,29 @@ func (c *Client) LimitedRequest(path string, results int) (string, int, error) {
 	return string(out), int(r["count"].(float64)), reqErr
 }
 
+// LimitedRequestAll is like LimitedRequest, but returns every result of a paginated list.
+// It asks for 100 results, then for all of them if the controller has more.
+func (c *Client) LimitedRequestAll(path string) (string, error) {
+	body, count, reqErr := c.LimitedRequest(path, 100)
+	if reqErr != nil && !IsErrAPIMismatch(reqErr) {
+		return "", reqErr
+	}
+
+	var results []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &results); err != nil {
+		return "", err
+	}
+
+	if count > len(results) {
+		body, _, reqErr = c.LimitedRequest(path, count)
+		if reqErr != nil && !IsErrAPIMismatch(reqErr) {
+			return "", reqErr
+		}
+	}
+
+	return body, reqErr
+}
+
 // CheckConnection checks that the user is connected to a network and the URL points to a valid controller.
 func (c *Client) CheckConnection() error {
 	errorMessage := `%s does not appear to be a valid Deis controller.
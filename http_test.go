@@ -29,6 +29,28 @@ const limitedFixture string = `
 }
 `
 
+const limitedAllFixture string = `
+{
+    "count": 4,
+    "next": null,
+    "previous": null,
+    "results": [
+        {
+            "test": "foo"
+        },
+        {
+            "test": "bar"
+        },
+        {
+            "test": "baz"
+        },
+        {
+            "test": "qux"
+        }
+    ]
+}
+`
+
 func (f fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Add("DEIS_API_VERSION", f.Version)
 	res.Header().Add("DEIS_PLATFORM_VERSION", f.PlatformVersion)
@@ -54,11 +76,17 @@ func (f fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if req.URL.Path == "/limited/" && req.Method == "GET" && req.URL.RawQuery == "limit=2" {
+	if req.URL.Path == "/limited/" && req.Method == "GET" && (req.URL.RawQuery == "limit=2" ||
+		req.URL.RawQuery == "limit=100") {
 		res.Write([]byte(limitedFixture))
 		return
 	}
 
+	if req.URL.Path == "/limited/" && req.Method == "GET" && req.URL.RawQuery == "limit=4" {
+		res.Write([]byte(limitedAllFixture))
+		return
+	}
+
 	if req.URL.Path == "/request/" && req.Method == "POST" {
 		eT := "token abc"
 		if req.Header.Get("Authorization") != eT {
@@ -229,6 +257,35 @@ func TestLimitedRequest(t *testing.T) {
 	}
 }
 
+func TestLimitedRequestAll(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{Version: APIVersion}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deis.UserAgent = "test"
+
+	expected := `[{"test":"foo"},{"test":"bar"},{"test":"baz"},{"test":"qux"}]`
+
+	actual, err := deis.LimitedRequestAll("/limited/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual != expected {
+		t.Errorf("Expected %s, Got %s", expected, actual)
+	}
+
+	if _, err := deis.LimitedRequestAll("/missing/"); err == nil {
+		t.Error("Expected an error for a missing list")
+	}
+}
+
 func TestHealthcheck(t *testing.T) {
 	t.Parallel()
 
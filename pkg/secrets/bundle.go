@@ -0,0 +1,308 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// BundleVersion is the version of the bundle format written by Seal.
+const BundleVersion = 1
+
+// KeySize is the size of bundle keys in bytes. Values are encrypted with AES-256-GCM.
+const KeySize = 32
+
+// Default scrypt parameters used by PassphraseKey.
+const (
+	DefaultScryptN = 1 << 15
+	DefaultScryptR = 8
+	DefaultScryptP = 1
+)
+
+// Bounds on the scrypt parameters of a bundle, which come from its untrusted header. They
+// keep the memory and CPU needed to derive a key, about 128 * N * r bytes, reasonable.
+const (
+	MaxScryptN  = 1 << 20
+	MaxScryptRP = 1 << 30
+)
+
+var (
+	// ErrInvalidKey is returned when a key or key file is malformed.
+	ErrInvalidKey = errors.New("invalid bundle key")
+	// ErrKeyNotFound is returned by Open when none of the keys has the bundle's key ID.
+	ErrKeyNotFound = errors.New("no key for bundle")
+	// ErrDecrypt is returned when a value cannot be decrypted, because the key or passphrase
+	// is wrong or the bundle was tampered with.
+	ErrDecrypt = errors.New("cannot decrypt bundle value")
+	// ErrUnsupportedBundle is returned when a bundle uses an unknown version or KDF.
+	ErrUnsupportedBundle = errors.New("unsupported bundle")
+)
+
+// KDF describes how a key was derived from a passphrase.
+type KDF struct {
+	// Name is the key derivation function. Only "scrypt" is supported.
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// Key encrypts and decrypts bundles. Keys are either random, usually kept in a key file
+// outside of the repository, or derived from a passphrase.
+type Key struct {
+	// ID identifies the key in bundles, so that the right key can be picked to open them
+	// and bundles sealed with an old key can be found after a rotation.
+	ID string
+	// KDF is set for keys derived from a passphrase, and is stored in the bundles they seal.
+	KDF    *KDF
+	secret []byte
+}
+
+// NewKey returns a random key. If id is empty, the key's fingerprint is used as its ID.
+func NewKey(id string) (Key, error) {
+	secret := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return Key{}, err
+	}
+	return newKey(id, secret, nil), nil
+}
+
+// KeyFromBytes returns a key from its raw secret, which must be KeySize bytes.
+// If id is empty, the key's fingerprint is used as its ID.
+func KeyFromBytes(id string, secret []byte) (Key, error) {
+	if len(secret) != KeySize {
+		return Key{}, fmt.Errorf("%w: keys must be %d bytes, got %d", ErrInvalidKey, KeySize, len(secret))
+	}
+	return newKey(id, append([]byte(nil), secret...), nil), nil
+}
+
+// PassphraseKey derives a key from a passphrase with scrypt and a random salt.
+// Bundles sealed with it can be opened with Bundle.PassphraseKey.
+func PassphraseKey(id string, passphrase string) (Key, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return Key{}, err
+	}
+	return deriveKey(id, passphrase, KDF{Name: "scrypt", Salt: salt, N: DefaultScryptN, R: DefaultScryptR, P: DefaultScryptP})
+}
+
+// ReadKeyFile reads a key written by WriteKeyFile: a single line with the key ID, a colon and
+// the base64 encoded secret. A line with only the secret is also accepted, in which case the
+// key's fingerprint is its ID.
+func ReadKeyFile(path string) (Key, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	id, encoded := "", strings.TrimSpace(string(b))
+	if i := strings.LastIndex(encoded, ":"); i >= 0 {
+		id, encoded = encoded[:i], encoded[i+1:]
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %s is not base64 encoded", ErrInvalidKey, path)
+	}
+	return KeyFromBytes(id, secret)
+}
+
+// WriteKeyFile writes a key to path with permissions 0600. Passphrase keys cannot be written,
+// as they are derived again from the passphrase.
+func WriteKeyFile(path string, key Key) error {
+	if key.KDF != nil || len(key.secret) != KeySize {
+		return fmt.Errorf("%w: only random keys can be written to a file", ErrInvalidKey)
+	}
+	line := key.ID + ":" + base64.StdEncoding.EncodeToString(key.secret) + "\n"
+	return ioutil.WriteFile(path, []byte(line), 0600)
+}
+
+// Fingerprint returns a short hash of the key's secret, safe to display.
+func (k Key) Fingerprint() string {
+	sum := sha256.Sum256(k.secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Bundle holds config values encrypted one by one, so that it can be committed and reviewed:
+// keys stay readable and diffs show which values changed. Each value is encrypted with
+// AES-256-GCM, authenticated together with the key ID and its name so values cannot be
+// swapped between keys or bundles sealed with different keys.
+type Bundle struct {
+	Version int `json:"version"`
+	// KeyID is the ID of the key that sealed the bundle.
+	KeyID string `json:"key_id"`
+	// KDF is set if the bundle was sealed with a passphrase key.
+	KDF *KDF `json:"kdf,omitempty"`
+	// Values are the encrypted values, base64 encoded with their nonce.
+	Values map[string]string `json:"values"`
+}
+
+// Seal encrypts config values into a bundle. Values that are not strings are formatted as in
+// the app's config; nil values, which unset keys, are left out.
+func Seal(values map[string]interface{}, key Key) (*Bundle, error) {
+	aead, err := key.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Version: BundleVersion, KeyID: key.ID, KDF: key.KDF, Values: make(map[string]string, len(values))}
+	for name, v := range values {
+		if v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(s), additionalData(key.ID, name))
+		b.Values[name] = base64.StdEncoding.EncodeToString(sealed)
+	}
+	return b, nil
+}
+
+// Open decrypts a bundle with the key whose ID matches the bundle's key ID.
+// It returns ErrKeyNotFound if no key matches and ErrDecrypt if a value cannot be decrypted.
+func Open(b *Bundle, keys ...Key) (map[string]interface{}, error) {
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedBundle, b.Version)
+	}
+
+	var key *Key
+	for i := range keys {
+		if keys[i].ID == b.KeyID {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: key ID %q", ErrKeyNotFound, b.KeyID)
+	}
+
+	aead, err := key.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(b.Values))
+	for name, encoded := range b.Values {
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("%w: %s is malformed", ErrDecrypt, name)
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, ciphertext, additionalData(b.KeyID, name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDecrypt, name)
+		}
+		values[name] = string(plain)
+	}
+	return values, nil
+}
+
+// PassphraseKey derives the key that sealed a passphrase bundle again from the passphrase.
+// A wrong passphrase is only detected when the bundle is opened, with ErrDecrypt.
+func (b *Bundle) PassphraseKey(passphrase string) (Key, error) {
+	if b.KDF == nil {
+		return Key{}, fmt.Errorf("%w: bundle %q was not sealed with a passphrase", ErrInvalidKey, b.KeyID)
+	}
+	return deriveKey(b.KeyID, passphrase, *b.KDF)
+}
+
+// Rotate decrypts the bundle with one of keys and seals it again with newKey.
+func (b *Bundle) Rotate(newKey Key, keys ...Key) (*Bundle, error) {
+	values, err := Open(b, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(values, newKey)
+}
+
+// ReadBundle decodes a bundle written by WriteBundle.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// WriteBundle encodes a bundle as indented JSON, with values in alphabetical order.
+func WriteBundle(w io.Writer, b *Bundle) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// LoadBundle reads a bundle from a file.
+func LoadBundle(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBundle(f)
+}
+
+func newKey(id string, secret []byte, kdf *KDF) Key {
+	k := Key{ID: id, KDF: kdf, secret: secret}
+	if k.ID == "" {
+		k.ID = k.Fingerprint()
+	}
+	return k
+}
+
+func deriveKey(id string, passphrase string, kdf KDF) (Key, error) {
+	if kdf.Name != "scrypt" {
+		return Key{}, fmt.Errorf("%w: key derivation %q", ErrUnsupportedBundle, kdf.Name)
+	}
+	if kdf.N > MaxScryptN || kdf.R <= 0 || kdf.P <= 0 ||
+		kdf.R >= MaxScryptRP || kdf.P >= MaxScryptRP || kdf.R*kdf.P >= MaxScryptRP {
+		return Key{}, fmt.Errorf("%w: scrypt parameters N=%d r=%d p=%d are out of bounds",
+			ErrUnsupportedBundle, kdf.N, kdf.R, kdf.P)
+	}
+	if passphrase == "" {
+		return Key{}, fmt.Errorf("%w: empty passphrase", ErrInvalidKey)
+	}
+	if id == "" {
+		return Key{}, fmt.Errorf("%w: passphrase keys need an ID", ErrInvalidKey)
+	}
+
+	secret, err := scrypt.Key([]byte(passphrase), kdf.Salt, kdf.N, kdf.R, kdf.P, KeySize)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return newKey(id, secret, &kdf), nil
+}
+
+func (k Key) aead() (cipher.AEAD, error) {
+	if len(k.secret) != KeySize {
+		return nil, fmt.Errorf("%w: key %q has no secret", ErrInvalidKey, k.ID)
+	}
+	block, err := aes.NewCipher(k.secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(keyID string, name string) []byte {
+	return []byte(keyID + "\x00" + name)
+}
@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != key.Fingerprint() || len(key.ID) != 16 {
+		t.Errorf("Expected the fingerprint as key ID, Got %q", key.ID)
+	}
+
+	values := map[string]interface{}{"DB_PASSWORD": "hunter2", "WORKERS": 4, "UNSET": nil}
+	b, err := Seal(values, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.KeyID != key.ID || b.KDF != nil || len(b.Values) != 2 {
+		t.Errorf("Unexpected bundle %+v", b)
+	}
+	if strings.Contains(b.Values["DB_PASSWORD"], "hunter2") {
+		t.Error("Expected the value to be encrypted")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewKey("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Open(read, other, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"DB_PASSWORD": "hunter2", "WORKERS": "4"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	if _, err := Open(read, other); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected %v, Got %v", ErrKeyNotFound, err)
+	}
+
+	// Values swapped between keys of the bundle don't decrypt.
+	read.Values["WORKERS"], read.Values["DB_PASSWORD"] = read.Values["DB_PASSWORD"], read.Values["WORKERS"]
+	if _, err := Open(read, key); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected %v, Got %v", ErrDecrypt, err)
+	}
+
+	// A key with the right ID but the wrong secret doesn't decrypt.
+	impostor, err := NewKey(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(b, impostor); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected %v, Got %v", ErrDecrypt, err)
+	}
+}
+
+func TestPassphraseBundle(t *testing.T) {
+	t.Parallel()
+
+	key, err := PassphraseKey("team", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Seal(map[string]interface{}{"API_TOKEN": "abc"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.KDF == nil || b.KDF.Name != "scrypt" || len(b.KDF.Salt) == 0 {
+		t.Fatalf("Expected scrypt parameters, Got %+v", b.KDF)
+	}
+
+	derived, err := b.PassphraseKey("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := Open(b, derived)
+	if err != nil || values["API_TOKEN"] != "abc" {
+		t.Errorf("Expected API_TOKEN=abc, Got %v %v", values, err)
+	}
+
+	wrong, err := b.PassphraseKey("incorrect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(b, wrong); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected %v, Got %v", ErrDecrypt, err)
+	}
+
+	// Parameters from the bundle header are bounded.
+	for _, kdf := range []KDF{
+		{Name: "scrypt", Salt: b.KDF.Salt, N: MaxScryptN << 1, R: DefaultScryptR, P: DefaultScryptP},
+		{Name: "scrypt", Salt: b.KDF.Salt, N: DefaultScryptN, R: 1 << 15, P: 1 << 15},
+		{Name: "scrypt", Salt: b.KDF.Salt, N: DefaultScryptN, R: -1, P: DefaultScryptP},
+	} {
+		tampered := *b
+		tampered.KDF = &kdf
+		if _, err := tampered.PassphraseKey("correct horse battery staple"); !errors.Is(err, ErrUnsupportedBundle) {
+			t.Errorf("%+v: Expected %v, Got %v", kdf, ErrUnsupportedBundle, err)
+		}
+	}
+
+	// Rotating to a random key drops the passphrase parameters.
+	newKey, err := NewKey("2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := b.Rotate(newKey, derived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.KeyID != "2024" || rotated.KDF != nil {
+		t.Errorf("Unexpected rotated bundle %+v", rotated)
+	}
+	if _, err := rotated.PassphraseKey("correct horse battery staple"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidKey, err)
+	}
+	if values, err := Open(rotated, newKey); err != nil || values["API_TOKEN"] != "abc" {
+		t.Errorf("Expected API_TOKEN=abc, Got %v %v", values, err)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := NewKey("prod-2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "key")
+	if err := WriteKeyFile(path, key); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, Got %v %v", info.Mode(), err)
+	}
+
+	read, err := ReadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(key, read) {
+		t.Errorf("Expected %v, Got %v", key, read)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("c2hvcnQ=\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadKeyFile(path); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidKey, err)
+	}
+
+	passphrase, err := PassphraseKey("team", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyFile(path, passphrase); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected %v, Got %v", ErrInvalidKey, err)
+	}
+}
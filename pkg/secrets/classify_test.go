@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsSensitive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Key       string
+		Value     string
+		Sensitive bool
+	}{
+		{"DB_PASSWORD", "x", true},
+		{"github_token", "x", true},
+		{"SECRET_KEY_BASE", "x", true},
+		{"AWS_SECRET_ACCESS_KEY", "x", true},
+		{"STRIPE_KEY", "x", true},
+		{"KEY", "x", true},
+		{"KEYBOARD_LAYOUT", "us", false},
+		{"DB_HOST", "db.example.com", false},
+		{"DATABASE_URL", "postgres://app:hunter2@db:5432/app", true},
+		{"DATABASE_URL", "postgres://app@db:5432/app", false},
+		{"SESSION", "f8Kq2LzX9vR4tW7mN1pB6yH3", true},
+		{"GREETING", "hello hello hello hello", false},
+		{"VERSION", "aaaaaaaaaaaaaaaaaaaaaaaa", false},
+	}
+
+	for _, test := range tests {
+		if actual := Default.IsSensitive(test.Key, test.Value); actual != test.Sensitive {
+			t.Errorf("%s=%s: Expected %v, Got %v", test.Key, test.Value, test.Sensitive, actual)
+		}
+	}
+
+	// A nil classifier behaves like Default.
+	var c *Classifier
+	if !c.IsSensitive("DB_PASSWORD", "x") {
+		t.Error("Expected a nil classifier to use the default patterns")
+	}
+}
+
+func TestClassifierPatterns(t *testing.T) {
+	t.Parallel()
+
+	c := &Classifier{Patterns: []string{"*_pin"}, Exclude: []string{"PUBLIC_*"}}
+
+	if !c.IsSensitive("CARD_PIN", "1234") {
+		t.Error("Expected CARD_PIN to be sensitive")
+	}
+	if c.IsSensitive("PUBLIC_PIN", "1234") {
+		t.Error("Expected excluded keys not to be sensitive")
+	}
+	if c.IsSensitive("DB_PASSWORD", "x") {
+		t.Error("Expected only the configured patterns to be used")
+	}
+	if c.IsSensitive("SESSION", "f8Kq2LzX9vR4tW7mN1pB6yH3") {
+		t.Error("Expected entropy detection to be disabled")
+	}
+}
+
+func TestMaskValues(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]interface{}{"API_TOKEN": "abc", "PORT": 5000, "PIN_KEY": 1234, "UNSET": nil}
+	expected := map[string]interface{}{"API_TOKEN": Mask, "PORT": 5000, "PIN_KEY": Mask, "UNSET": nil}
+
+	if actual := Default.MaskValues(values); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+	if values["API_TOKEN"] != "abc" {
+		t.Error("Expected the values not to be modified")
+	}
+
+	registry := map[string]interface{}{"bob": "hunter2", "alice": nil}
+	expected = map[string]interface{}{"bob": Mask, "alice": nil}
+	if actual := MaskAll(registry); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
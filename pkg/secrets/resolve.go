@@ -0,0 +1,247 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidReference is returned when a secret reference cannot be parsed.
+	ErrInvalidReference = errors.New("invalid secret reference")
+	// ErrSecretNotFound is returned by providers when a referenced secret does not exist.
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// Reference is a pointer to a secret kept outside of the app's config, written as a URL:
+//
+//    file:///run/secrets/db          the contents of a file
+//    file:///run/secrets/db.json#pw  a key of a JSON object in a file
+//    env://DB_PASSWORD               an environment variable
+//    secret://prod/db#password       a key of a secret in a store registered for "secret"
+type Reference struct {
+	// Scheme selects the provider, such as "file" or "env".
+	Scheme string
+	// Path locates the secret within the provider: a file path, a variable name or a store path.
+	Path string
+	// Key optionally selects a field of the secret, from the URL fragment.
+	Key string
+	// Raw is the reference as written.
+	Raw string
+}
+
+// String returns the reference as written.
+func (r Reference) String() string {
+	return r.Raw
+}
+
+// ParseReference parses a secret reference. See Reference for the syntax.
+func ParseReference(s string) (Reference, error) {
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, s)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, s)
+	}
+
+	ref := Reference{Scheme: u.Scheme, Path: u.Host + u.Path, Key: u.Fragment, Raw: s}
+	if ref.Path == "" {
+		return Reference{}, fmt.Errorf("%w: %q has no path", ErrInvalidReference, s)
+	}
+	return ref, nil
+}
+
+// Provider fetches secrets for a reference scheme.
+type Provider interface {
+	Resolve(ref Reference) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ref Reference) (string, error)
+
+// Resolve calls f(ref).
+func (f ProviderFunc) Resolve(ref Reference) (string, error) {
+	return f(ref)
+}
+
+// FileProvider resolves file:// references to the contents of the file, without a trailing
+// newline. If the reference has a key, the file must hold a JSON object and the key's value
+// is returned.
+var FileProvider Provider = ProviderFunc(resolveFile)
+
+// EnvProvider resolves env:// references to the value of an environment variable.
+var EnvProvider Provider = ProviderFunc(resolveEnv)
+
+// Resolver replaces secret references in config values with the secrets they point to.
+// Only values starting with the scheme of a registered provider, such as file://, are
+// references; other values, including URLs such as postgres://, are left as they are.
+type Resolver struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver with the file and env providers registered.
+func NewResolver() *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("file", FileProvider)
+	r.Register("env", EnvProvider)
+	return r
+}
+
+// DefaultResolver is the resolver used by config.SetResolved and the dotenv helpers.
+// Register a provider for "secret" on it to resolve secret:// references from a secrets store.
+var DefaultResolver = NewResolver()
+
+// Register adds a provider for a scheme, replacing any existing one.
+// Registering a nil provider removes the scheme.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scheme = strings.ToLower(scheme)
+	if p == nil {
+		delete(r.providers, scheme)
+		return
+	}
+	r.providers[scheme] = p
+}
+
+// Register adds a provider for a scheme to DefaultResolver.
+func Register(scheme string, p Provider) {
+	DefaultResolver.Register(scheme, p)
+}
+
+// IsReference returns true if the value is a reference to a registered scheme.
+func (r *Resolver) IsReference(value string) bool {
+	return r.provider(value) != nil
+}
+
+// Resolve returns the secret a reference points to, or value itself if it is not a reference.
+func (r *Resolver) Resolve(value string) (string, error) {
+	p := r.provider(value)
+	if p == nil {
+		return value, nil
+	}
+
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := p.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	return secret, nil
+}
+
+// ResolveValues returns a copy of config values with every reference resolved.
+// Values that are not strings or not references are copied unchanged. If several references
+// fail to resolve, the error is about the first key in alphabetical order.
+func (r *Resolver) ResolveValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resolved := make(map[string]interface{}, len(values))
+	for _, k := range keys {
+		s, ok := values[k].(string)
+		if !ok {
+			resolved[k] = values[k]
+			continue
+		}
+		secret, err := r.Resolve(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		resolved[k] = secret
+	}
+	return resolved, nil
+}
+
+// Unresolve returns a copy of values where the values that refs resolve to are replaced by
+// the references, so that exports can write references back instead of secrets.
+// refs maps config keys to references, typically from a previously exported file. A reference
+// is only written back if it still resolves to the current value; otherwise the value was
+// changed on the controller and is kept, as is any value whose reference fails to resolve.
+func (r *Resolver) Unresolve(values map[string]interface{}, refs map[string]string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		out[k] = v
+		ref, ok := refs[k]
+		if !ok || !r.IsReference(ref) {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if secret, err := r.Resolve(ref); err == nil && secret == s {
+			out[k] = ref
+		}
+	}
+	return out
+}
+
+func (r *Resolver) provider(value string) Provider {
+	i := strings.Index(value, "://")
+	if i <= 0 {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.providers[strings.ToLower(value[:i])]
+}
+
+func resolveFile(ref Reference) (string, error) {
+	b, err := ioutil.ReadFile(ref.Path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref.Path)
+	} else if err != nil {
+		return "", err
+	}
+
+	if ref.Key == "" {
+		return strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"), nil
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return "", fmt.Errorf("%s is not a JSON object: %w", ref.Path, err)
+	}
+	v, ok := fields[ref.Key]
+	if !ok || v == nil {
+		return "", fmt.Errorf("%w: %s has no key %q", ErrSecretNotFound, ref.Path, ref.Key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err = json.Marshal(v)
+	return string(b), err
+}
+
+func resolveEnv(ref Reference) (string, error) {
+	v, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, ref.Path)
+	}
+	return v, nil
+}
@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Input    string
+		Expected Reference
+	}{
+		{"file:///run/secrets/db", Reference{Scheme: "file", Path: "/run/secrets/db"}},
+		{"env://DB_PASSWORD", Reference{Scheme: "env", Path: "DB_PASSWORD"}},
+		{"secret://prod/db#password", Reference{Scheme: "secret", Path: "prod/db", Key: "password"}},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseReference(test.Input)
+		if err != nil {
+			t.Errorf("%s: %v", test.Input, err)
+			continue
+		}
+		test.Expected.Raw = test.Input
+		if !reflect.DeepEqual(test.Expected, actual) {
+			t.Errorf("%s: Expected %#v, Got %#v", test.Input, test.Expected, actual)
+		}
+	}
+
+	for _, input := range []string{"", "plain", "://x", "env://", "file://%zz"} {
+		if _, err := ParseReference(input); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("%q: Expected %v, Got %v", input, ErrInvalidReference, err)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "db")
+	if err := ioutil.WriteFile(plain, []byte("hunter2\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	object := filepath.Join(dir, "db.json")
+	if err := ioutil.WriteFile(object, []byte(`{"user":"app","port":5432}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := map[string]string{"prod/db#password": "s3cret"}
+	r := NewResolver()
+	r.Register("SECRET", ProviderFunc(func(ref Reference) (string, error) {
+		if v, ok := store[ref.Path+"#"+ref.Key]; ok {
+			return v, nil
+		}
+		return "", ErrSecretNotFound
+	}))
+
+	values := map[string]interface{}{
+		"PASSWORD":     "file://" + plain,
+		"USER":         "file://" + object + "#user",
+		"PORT":         "file://" + object + "#port",
+		"STORE":        "secret://prod/db#password",
+		"DATABASE_URL": "postgres://db/app",
+		"WORKERS":      4,
+		"UNSET":        nil,
+	}
+	expected := map[string]interface{}{
+		"PASSWORD":     "hunter2",
+		"USER":         "app",
+		"PORT":         "5432",
+		"STORE":        "s3cret",
+		"DATABASE_URL": "postgres://db/app",
+		"WORKERS":      4,
+		"UNSET":        nil,
+	}
+
+	actual, err := r.ResolveValues(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	for _, ref := range []string{
+		"file://" + filepath.Join(dir, "missing"),
+		"file://" + object + "#missing",
+		"secret://prod/other#password",
+	} {
+		if _, err := r.Resolve(ref); !errors.Is(err, ErrSecretNotFound) {
+			t.Errorf("%s: Expected %v, Got %v", ref, ErrSecretNotFound, err)
+		}
+	}
+
+	r.Register("secret", nil)
+	if r.IsReference("secret://prod/db#password") {
+		t.Error("Expected the secret scheme to be removed")
+	}
+
+	refs := map[string]string{"PASSWORD": "file://" + plain, "USER": "file://" + object + "#port", "PORT": "5432"}
+	current := map[string]interface{}{"PASSWORD": "hunter2", "USER": "app", "PORT": "5432"}
+	expected = map[string]interface{}{"PASSWORD": "file://" + plain, "USER": "app", "PORT": "5432"}
+	if actual := r.Unresolve(current, refs); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("SECRETS_TEST_VALUE", "from-env")
+
+	if v, err := DefaultResolver.Resolve("env://SECRETS_TEST_VALUE"); err != nil || v != "from-env" {
+		t.Errorf("Expected from-env, Got %q %v", v, err)
+	}
+
+	if _, err := DefaultResolver.Resolve("env://SECRETS_TEST_MISSING"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected %v, Got %v", ErrSecretNotFound, err)
+	}
+}
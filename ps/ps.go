@@ -31,7 +31,10 @@ func List(c *deis.Client, appID string, results int) (api.PodsList, []string, in
 	// Retrieves all Procfile Processes
 	uapp := fmt.Sprintf("/v2/apps/%s/", appID)
 
-	resApp, _ := c.Request("GET", uapp, nil)
+	resApp, appErr := c.Request("GET", uapp, nil)
+	if appErr != nil && !deis.IsErrAPIMismatch(appErr) {
+		return []api.Pods{}, nil, -1, appErr
+	}
 	defer resApp.Body.Close()
 
 	// appResult := api.AppProcfileProcess{}
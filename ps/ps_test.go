@@ -1,12 +1,15 @@
 package ps
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	gotime "time"
 
 	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
 	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
@@ -29,6 +32,16 @@ const processesFixture string = `
     ]
 }`
 
+const appFixture string = `
+{
+    "id": "example-go",
+    "owner": "test",
+    "procfile_structure": {
+        "web": "example-go",
+        "worker": "example-go-worker"
+    }
+}`
+
 const restartAllFixture string = `[
     {
         "release": "v2",
@@ -74,6 +87,11 @@ func (fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if req.URL.Path == "/v2/apps/example-go/" && req.Method == "GET" {
+		res.Write([]byte(appFixture))
+		return
+	}
+
 	if req.URL.Path == "/v2/apps/example-go/pods/restart/" && req.Method == "POST" {
 		res.Write([]byte(restartAllFixture))
 		return
@@ -144,7 +162,7 @@ func TestProcessesList(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	actual, _, err := List(deis, "example-go", 100)
+	actual, scaledDown, _, err := List(deis, "example-go", 100)
 
 	if err != nil {
 		t.Fatal(err)
@@ -153,6 +171,10 @@ func TestProcessesList(t *testing.T) {
 	if !reflect.DeepEqual(expected, actual) {
 		t.Error(fmt.Errorf("Expected %v, Got %v", expected, actual))
 	}
+
+	if !reflect.DeepEqual([]string{"worker"}, scaledDown) {
+		t.Error(fmt.Errorf("Expected %v, Got %v", []string{"worker"}, scaledDown))
+	}
 }
 
 type testExpected struct {
@@ -311,3 +333,56 @@ func TestByType(t *testing.T) {
 		t.Errorf("Expected: %v, Got %v", expected, actual)
 	}
 }
+
+func TestNotReady(t *testing.T) {
+	t.Parallel()
+
+	pods := api.PodsList{
+		{Name: "web-1", Release: "v2", State: "up"},
+		{Name: "web-2", Release: "v2", State: "starting"},
+		{Name: "web-3", Release: "v1", State: "terminating"},
+		{Name: "web-4", Release: "", State: "up"},
+	}
+
+	expected := api.PodsList{pods[1], pods[2], pods[3]}
+	if actual := NotReady(pods, 2); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+
+	if v := ReleaseVersion(pods[0]); v != 2 {
+		t.Errorf("Expected 2, Got %d", v)
+	}
+	if v := ReleaseVersion(pods[3]); v != -1 {
+		t.Errorf("Expected -1, Got %d", v)
+	}
+}
+
+func TestWaitForVersionNotReady(t *testing.T) {
+	t.Parallel()
+
+	handler := fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	deis, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := WaitForVersion(context.Background(), deis, "example-go", 2, gotime.Millisecond)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("Expected the pods of v2, Got %v %v", pods, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*gotime.Millisecond)
+	defer cancel()
+
+	_, err = WaitForVersion(ctx, deis, "example-go", 3, gotime.Millisecond)
+	notReady, ok := err.(NotReadyError)
+	if !ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a NotReadyError, Got %v", err)
+	}
+	if notReady.Version != 3 || len(notReady.Pending) != 1 || notReady.Pending[0].Name != "example-go-v2-web-45678" {
+		t.Errorf("Unexpected error %+v", notReady)
+	}
+}
@@ -0,0 +1,117 @@
+package ps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+// UpState is the state of pods that are running and ready.
+const UpState = "up"
+
+// DefaultPollInterval is the interval between two listings of an app's pods in WaitForVersion.
+const DefaultPollInterval = 5 * time.Second
+
+// ListAll lists all of an app's processes, fetching every page.
+func ListAll(c *deis.Client, appID string) (api.PodsList, error) {
+	u := fmt.Sprintf("/v2/apps/%s/pods/", appID)
+	body, reqErr := c.LimitedRequestAll(u)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	var pods api.PodsList
+	if err := json.Unmarshal([]byte(body), &pods); err != nil {
+		return nil, err
+	}
+	return pods, reqErr
+}
+
+// NotReady returns the pods that are not up or do not run the release version.
+func NotReady(pods api.PodsList, version int) api.PodsList {
+	var pending api.PodsList
+	for _, pod := range pods {
+		if pod.State != UpState || ReleaseVersion(pod) != version {
+			pending = append(pending, pod)
+		}
+	}
+	return pending
+}
+
+// ReleaseVersion returns the release version a pod runs, parsed from its release such as "v5",
+// or -1 if it cannot be parsed.
+func ReleaseVersion(pod api.Pods) int {
+	v, err := strconv.Atoi(strings.TrimPrefix(pod.Release, "v"))
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// NotReadyError is returned by WaitForVersion when its context is done before the pods of
+// the app are ready. It unwraps to the context's error.
+type NotReadyError struct {
+	App     string
+	Version int
+	// Pending are the pods that were not ready when the context was done.
+	Pending api.PodsList
+	Err     error
+}
+
+func (e NotReadyError) Error() string {
+	return fmt.Sprintf("pods of %s did not all run v%d before the wait ended (%v), not ready: %s",
+		e.App, e.Version, e.Err, describe(e.Pending))
+}
+
+func (e NotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForVersion polls an app's pods every interval until they all run the release version and
+// are up, and returns them. Old pods that are still terminating count as not ready.
+// If the context is done first, it returns the last pods listed and a NotReadyError, which
+// lists the pods that are not ready and unwraps to the context's error.
+// If interval is zero, DefaultPollInterval is used.
+func WaitForVersion(ctx context.Context, c *deis.Client, appID string, version int,
+	interval time.Duration) (api.PodsList, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pods api.PodsList
+	for {
+		listed, err := ListAll(c, appID)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return pods, err
+		}
+		pods = listed
+
+		pending := NotReady(pods, version)
+		if len(pending) == 0 {
+			return pods, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return pods, NotReadyError{App: appID, Version: version, Pending: pending, Err: ctx.Err()}
+		case <-ticker.C:
+		}
+	}
+}
+
+func describe(pods api.PodsList) string {
+	names := make([]string, len(pods))
+	for i, pod := range pods {
+		names[i] = fmt.Sprintf("%s %s %s", pod.Name, pod.Release, pod.State)
+	}
+	return strings.Join(names, ", ")
+}
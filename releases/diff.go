@@ -0,0 +1,278 @@
+package releases
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/builds"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+)
+
+// Sections of a release diff that come from builds. The other sections are those of
+// config.Compare, such as config.SectionValues for environment variables.
+const (
+	SectionImage    = "image"
+	SectionSha      = "sha"
+	SectionProcfile = "procfile"
+)
+
+// Snapshot is a release with the build and config it deploys.
+type Snapshot struct {
+	Release api.Release
+	// Build is empty for releases without a build, such as the initial release.
+	Build api.Build
+	// Config is empty if ConfigUnavailable is set: the controller only serves an app's current
+	// config, not the configs of earlier releases.
+	Config            api.Config
+	ConfigUnavailable bool
+}
+
+// Diff is the set of differences between two releases of an app.
+type Diff struct {
+	App  string      `json:"app"`
+	From api.Release `json:"from"`
+	To   api.Release `json:"to"`
+	// Changes are ordered by section, build sections first, then by key.
+	// Image and sha changes are keyed by their section; procfile changes by process type.
+	Changes []config.Change `json:"changes"`
+	// ConfigFromSummaries is true if the config of either release was unavailable, so the
+	// config changes were derived from the summaries of the releases in between. Summaries only
+	// name the environment variables, tags, limits and healthchecks that changed, so their values
+	// are masked, limits are keyed by "*" since summaries do not name their process types, and
+	// rollbacks in between are not accounted for.
+	ConfigFromSummaries bool `json:"config_from_summaries,omitempty"`
+}
+
+// Empty returns true if the releases deploy the same build and config.
+func (d Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Section returns the changes of a section, such as SectionProcfile or config.SectionValues.
+func (d Diff) Section(section string) []config.Change {
+	var changes []config.Change
+	for _, c := range d.Changes {
+		if c.Section == section {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// String renders the diff in unified diff format, with a hunk for each changed section.
+func (d Diff) String() string {
+	return config.Diff{
+		From:    fmt.Sprintf("%s v%d", d.App, d.From.Version),
+		To:      fmt.Sprintf("%s v%d", d.App, d.To.Version),
+		Changes: d.Changes,
+	}.String()
+}
+
+// Compare returns the differences between two versions of an app: the image, git sha and
+// procfile of their builds, and their configs as compared by config.Compare, which masks
+// secret values unless opts.ShowSecrets is set. Only the current release's config is
+// available from the controller, so for other releases the config changes are derived from
+// release summaries, see Diff.ConfigFromSummaries.
+func Compare(c *deis.Client, appID string, from int, to int, opts config.DiffOptions) (Diff, error) {
+	fromSnapshot, err := GetSnapshot(c, appID, from)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	toSnapshot, err := GetSnapshot(c, appID, to)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	d := CompareSnapshots(fromSnapshot, toSnapshot, opts)
+	d.App = appID
+	if fromSnapshot.ConfigUnavailable || toSnapshot.ConfigUnavailable {
+		releases, err := ListAll(c, appID)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return Diff{}, err
+		}
+		d = withSummaryChanges(d, releases)
+	}
+	return d, nil
+}
+
+// GetSnapshot retrieves a release of an app with its build and config.
+func GetSnapshot(c *deis.Client, appID string, version int) (Snapshot, error) {
+	release, err := Get(c, appID, version)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return Snapshot{}, err
+	}
+	return snapshot(c, appID, release)
+}
+
+// snapshot retrieves the build and config of a release.
+func snapshot(c *deis.Client, appID string, release api.Release) (Snapshot, error) {
+	s := Snapshot{Release: release}
+
+	var err error
+	if release.Build != "" {
+		if s.Build, err = builds.Get(c, appID, release.Build); err != nil && !deis.IsErrAPIMismatch(err) {
+			return Snapshot{}, fmt.Errorf("build of v%d: %w", release.Version, err)
+		}
+	}
+
+	if release.Config != "" {
+		s.Config, err = config.GetCurrent(c, appID, release.Config)
+		switch {
+		case errors.Is(err, config.ErrConfigUnavailable):
+			s.ConfigUnavailable = true
+		case err != nil && !deis.IsErrAPIMismatch(err):
+			return Snapshot{}, fmt.Errorf("config of v%d: %w", release.Version, err)
+		}
+	}
+
+	return s, nil
+}
+
+// CompareSnapshots returns the differences between two releases and their builds and configs.
+// Configs are not compared if either is unavailable.
+func CompareSnapshots(from Snapshot, to Snapshot, opts config.DiffOptions) Diff {
+	d := Diff{App: to.Release.App, From: from.Release, To: to.Release, Changes: []config.Change{}}
+
+	d.Changes = appendChange(d.Changes, SectionImage, SectionImage, from.Build.Image, to.Build.Image)
+	d.Changes = appendChange(d.Changes, SectionSha, SectionSha, from.Build.Sha, to.Build.Sha)
+
+	types := make([]string, 0, len(from.Build.Procfile)+len(to.Build.Procfile))
+	for t := range from.Build.Procfile {
+		types = append(types, t)
+	}
+	for t := range to.Build.Procfile {
+		if _, ok := from.Build.Procfile[t]; !ok {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		d.Changes = appendChange(d.Changes, SectionProcfile, t, from.Build.Procfile[t], to.Build.Procfile[t])
+	}
+
+	if !from.ConfigUnavailable && !to.ConfigUnavailable {
+		d.Changes = append(d.Changes, config.Compare(from.Config, to.Config, opts).Changes...)
+	}
+	return d
+}
+
+// summarySections orders the config sections withSummaryChanges derives, as config.Compare does.
+var summarySections = map[string]int{config.SectionValues: 0, config.SectionMemory: 1, config.SectionCPU: 2,
+	config.SectionHealthcheck: 3, config.SectionTags: 4}
+
+// withSummaryChanges adds the config changes between the releases of a diff, derived from the
+// summaries of releases, an app's releases in any order.
+func withSummaryChanges(d Diff, releases []api.Release) Diff {
+	low, high := d.From.Version, d.To.Version
+	if low > high {
+		low, high = high, low
+	}
+	between := []api.Release{}
+	for _, release := range releases {
+		if release.Version > low && release.Version <= high {
+			between = append(between, release)
+		}
+	}
+	sort.Slice(between, func(i, j int) bool { return between[i].Version < between[j].Version })
+
+	// existed is whether an entry existed before its first change, exists whether it does after
+	// the last one.
+	type state struct{ existed, exists bool }
+	states := map[[2]string]*state{}
+	change := func(section string, key string, action string) {
+		k := [2]string{section, key}
+		if states[k] == nil {
+			states[k] = &state{existed: action != "added"}
+		}
+		states[k].exists = action != "deleted"
+	}
+	for _, release := range between {
+		for _, event := range ParseSummary(release.Summary) {
+			switch event.Kind {
+			case KindConfig:
+				section := config.SectionValues
+				if strings.HasPrefix(event.Subject, "tag ") {
+					section = config.SectionTags
+				} else if strings.HasPrefix(event.Subject, "registry") {
+					continue
+				}
+
+				action := event.Action
+				for _, item := range strings.Split(event.Subject, ", ") {
+					for _, a := range []string{"added", "changed", "deleted"} {
+						if strings.HasPrefix(item, a+" ") {
+							action, item = a, strings.TrimPrefix(item, a+" ")
+						}
+					}
+					change(section, strings.TrimPrefix(item, "tag "), action)
+				}
+			case KindLimits:
+				for _, item := range strings.Split(strings.TrimPrefix(event.Subject, "limits for "), ", ") {
+					if item == config.SectionMemory || item == config.SectionCPU {
+						change(item, "*", "changed")
+					}
+				}
+			case KindHealthcheck:
+				for _, item := range strings.Split(strings.TrimPrefix(event.Subject, "healthcheck for "), ", ") {
+					if item != "" && item != event.Subject {
+						change(config.SectionHealthcheck, item, event.Action)
+					}
+				}
+			}
+		}
+	}
+
+	forward := d.From.Version < d.To.Version
+	changes := []config.Change{}
+	for key, s := range states {
+		if !s.existed && !s.exists {
+			continue
+		}
+		c := config.Change{Section: key[0], Key: key[1], Masked: true}
+		switch {
+		case s.existed && s.exists:
+			c.Type = config.Changed
+		case s.exists == forward:
+			c.Type = config.Added
+		default:
+			c.Type = config.Removed
+		}
+		if c.Type != config.Added {
+			c.Old = config.Mask
+		}
+		if c.Type != config.Removed {
+			c.New = config.Mask
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return summarySections[changes[i].Section] < summarySections[changes[j].Section]
+		}
+		return changes[i].Key < changes[j].Key
+	})
+
+	d.Changes = append(d.Changes, changes...)
+	d.ConfigFromSummaries = true
+	return d
+}
+
+func appendChange(changes []config.Change, section string, key string, old string, new string) []config.Change {
+	c := config.Change{Section: section, Key: key, Old: old, New: new}
+	switch {
+	case old == new:
+		return changes
+	case old == "":
+		c.Type = config.Added
+	case new == "":
+		c.Type = config.Removed
+	default:
+		c.Type = config.Changed
+	}
+	return append(changes, c)
+}
@@ -0,0 +1,176 @@
+package releases
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+)
+
+var diffFixtures = map[string]string{
+	"/v2/apps/example-go/releases/v41/": `{"app": "example-go", "build": "b41", "config": "c41", "owner": "test",
+		"summary": "alice deployed 1a2b3c4", "created": "2014-01-01T00:00:00UTC", "uuid": "r41", "version": 41}`,
+	"/v2/apps/example-go/releases/v45/": `{"app": "example-go", "build": "b45", "config": "c45", "owner": "test",
+		"summary": "bob added DATABASE_URL", "created": "2014-01-02T00:00:00UTC", "uuid": "r45", "version": 45}`,
+	"/v2/apps/example-go/builds/b41/": `{"app": "example-go", "image": "deis/example-go:v1", "sha": "1a2b3c4",
+		"procfile": {"web": "bin/web", "worker": "bin/worker"}, "uuid": "b41"}`,
+	"/v2/apps/example-go/builds/b45/": `{"app": "example-go", "image": "deis/example-go:v2", "sha": "5d6e7f8",
+		"procfile": {"web": "bin/web --fast", "clock": "bin/clock"}, "uuid": "b45"}`,
+	"/v2/apps/example-go/config/": currentConfigFixture,
+	// The summaries of v42 to v45 are as the controller writes them.
+	"/v2/apps/example-go/releases/": `{"count": 5, "next": null, "previous": null, "results": [
+		{"version": 45, "summary": "bob added DATABASE_URL, changed API_TOKENbob changed limits for memory, cpu"},
+		{"version": 44, "summary": "bob deleted DEBUG and bob added tag env"},
+		{"version": 43, "summary": "bob deleted TMPbob added healthcheck for web"},
+		{"version": 42, "summary": "alice deployed 5d6e7f8 and bob added TMP"},
+		{"version": 41, "summary": "alice deployed 1a2b3c4"}
+	]}`,
+}
+
+const previousConfigFixture = `{"app": "example-go", "values": {"DEBUG": "true", "API_TOKEN": "old"},
+	"memory": {"web": "1G"}, "uuid": "c41"}`
+
+const currentConfigFixture = `{"app": "example-go", "values": {"DATABASE_URL": "postgres://db/app", "API_TOKEN": "new"},
+	"memory": {"web": "2G"}, "cpu": {"web": "500m"},
+	"healthcheck": {"web": {"livenessProbe": {"timeoutSeconds": 5, "periodSeconds": 10, "successThreshold": 1,
+	"failureThreshold": 3, "tcpSocket": {"port": 5000}}}}, "uuid": "c45"}`
+
+type diffHTTPServer struct{}
+
+func (diffHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if fixture, ok := diffFixtures[req.URL.Path]; ok && req.Method == "GET" {
+		res.Write([]byte(fixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	handler := diffHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Compare(client, "example-go", 41, 45, config.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.App != "example-go" || d.From.Version != 41 || d.To.Version != 45 {
+		t.Errorf("Unexpected diff header %v %v %v", d.App, d.From, d.To)
+	}
+
+	// Only the config of v45 is available, the config changes come from the summaries.
+	expected := []config.Change{
+		{Section: SectionImage, Key: SectionImage, Type: config.Changed, Old: "deis/example-go:v1", New: "deis/example-go:v2"},
+		{Section: SectionSha, Key: SectionSha, Type: config.Changed, Old: "1a2b3c4", New: "5d6e7f8"},
+		{Section: SectionProcfile, Key: "clock", Type: config.Added, New: "bin/clock"},
+		{Section: SectionProcfile, Key: "web", Type: config.Changed, Old: "bin/web", New: "bin/web --fast"},
+		{Section: SectionProcfile, Key: "worker", Type: config.Removed, Old: "bin/worker"},
+		{Section: config.SectionValues, Key: "API_TOKEN", Type: config.Changed, Old: config.Mask, New: config.Mask, Masked: true},
+		{Section: config.SectionValues, Key: "DATABASE_URL", Type: config.Added, New: config.Mask, Masked: true},
+		{Section: config.SectionValues, Key: "DEBUG", Type: config.Removed, Old: config.Mask, Masked: true},
+		{Section: config.SectionMemory, Key: "*", Type: config.Changed, Old: config.Mask, New: config.Mask, Masked: true},
+		{Section: config.SectionCPU, Key: "*", Type: config.Changed, Old: config.Mask, New: config.Mask, Masked: true},
+		{Section: config.SectionHealthcheck, Key: "web", Type: config.Added, New: config.Mask, Masked: true},
+		{Section: config.SectionTags, Key: "env", Type: config.Added, New: config.Mask, Masked: true},
+	}
+	if !reflect.DeepEqual(expected, d.Changes) || !d.ConfigFromSummaries {
+		t.Errorf("Expected %v, Got %v", expected, d.Changes)
+	}
+
+	reverse, err := Compare(client, "example-go", 45, 41, config.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := reverse.Section(config.SectionValues); len(values) != 3 || values[1].Type != config.Removed ||
+		values[2].Type != config.Added {
+		t.Errorf("Expected the reverse config changes, Got %v", values)
+	}
+	if healthchecks := reverse.Section(config.SectionHealthcheck); len(healthchecks) != 1 || healthchecks[0].Type != config.Removed {
+		t.Errorf("Expected the healthcheck of web to be removed, Got %v", healthchecks)
+	}
+
+	if procfile := d.Section(SectionProcfile); len(procfile) != 3 {
+		t.Errorf("Expected 3 procfile changes, Got %v", procfile)
+	}
+
+	s := d.String()
+	for _, line := range []string{"--- example-go v41\n", "+++ example-go v45\n", "@@ image @@\n-image=deis/example-go:v1\n+image=deis/example-go:v2\n"} {
+		if !strings.Contains(s, line) {
+			t.Errorf("Expected %q in %s", line, s)
+		}
+	}
+
+	if _, err := Compare(client, "example-go", 41, 46, config.DiffOptions{}); err == nil {
+		t.Error("Expected an error for a missing release")
+	}
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	t.Parallel()
+
+	from, to := Snapshot{}, Snapshot{}
+	if err := json.Unmarshal([]byte(previousConfigFixture), &from.Config); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(currentConfigFixture), &to.Config); err != nil {
+		t.Fatal(err)
+	}
+
+	healthcheck := `{"initialDelaySeconds":0,"timeoutSeconds":5,"periodSeconds":10,"successThreshold":1,"failureThreshold":3,"tcpSocket":{"port":5000}}`
+	expected := []config.Change{
+		{Section: config.SectionValues, Key: "API_TOKEN", Type: config.Changed, Old: config.Mask, New: config.Mask, Masked: true},
+		{Section: config.SectionValues, Key: "DATABASE_URL", Type: config.Added, New: "postgres://db/app"},
+		{Section: config.SectionValues, Key: "DEBUG", Type: config.Removed, Old: "true"},
+		{Section: config.SectionMemory, Key: "web", Type: config.Changed, Old: "1G", New: "2G"},
+		{Section: config.SectionCPU, Key: "web", Type: config.Added, New: "500m"},
+		{Section: config.SectionHealthcheck, Key: "web/livenessProbe", Type: config.Added, New: healthcheck},
+	}
+	d := CompareSnapshots(from, to, config.DiffOptions{})
+	if !reflect.DeepEqual(expected, d.Changes) || d.ConfigFromSummaries {
+		t.Errorf("Expected %v, Got %v", expected, d.Changes)
+	}
+
+	from.ConfigUnavailable = true
+	if d := CompareSnapshots(from, to, config.DiffOptions{}); !d.Empty() {
+		t.Errorf("Expected no config changes, Got %v", d.Changes)
+	}
+}
+
+func TestCompareSnapshotsWithoutBuild(t *testing.T) {
+	t.Parallel()
+
+	from := Snapshot{}
+	from.Release.Version = 1
+	to := Snapshot{}
+	to.Release.Version = 2
+	to.Build.Image = "deis/example-go"
+
+	d := CompareSnapshots(from, to, config.DiffOptions{})
+	expected := []config.Change{{Section: SectionImage, Key: SectionImage, Type: config.Added, New: "deis/example-go"}}
+	if !reflect.DeepEqual(expected, d.Changes) {
+		t.Errorf("Expected %v, Got %v", expected, d.Changes)
+	}
+
+	if !CompareSnapshots(to, to, config.DiffOptions{}).Empty() {
+		t.Error("Expected no changes between a release and itself")
+	}
+}
@@ -0,0 +1,271 @@
+package releases
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	dtime "github.com/trilogy-group/devgraph-eyk-controller-sdk-go/pkg/time"
+)
+
+// Kind is the kind of change a release summary describes.
+type Kind string
+
+const (
+	// KindInitial is the release created with an app, which has no build.
+	KindInitial Kind = "initial"
+	// KindDeploy is a new build, as in "alice deployed 1a2b3c4".
+	KindDeploy Kind = "deploy"
+	// KindConfig is a change of environment variables, tags or registry settings,
+	// as in "bob added DATABASE_URL, changed DEBUG".
+	KindConfig Kind = "config"
+	// KindRollback is a rollback, as in "alice rolled back to v5".
+	KindRollback Kind = "rollback"
+	// KindLimits is a change of memory or cpu limits, as in "bob changed limits for memory".
+	KindLimits Kind = "limits"
+	// KindHealthcheck is a change of healthchecks, as in "bob changed healthcheck for web".
+	KindHealthcheck Kind = "healthcheck"
+	// KindVolume is a change of volumes or their mounts, as in "bob added volume data".
+	KindVolume Kind = "volume"
+	// KindUnknown is a change the summary parser does not recognize.
+	KindUnknown Kind = "unknown"
+)
+
+// Event is one change described by a release summary. A summary may describe several, such as
+// "alice deployed 1a2b3c4 and alice added DATABASE_URL". Scaling does not create a release, so
+// it has no kind.
+type Event struct {
+	Kind Kind `json:"kind"`
+	// Actor is the user who made the change.
+	Actor string `json:"actor"`
+	// Action is the verb of the change, such as "deployed", "added" or "rolled back to".
+	Action string `json:"action"`
+	// Subject is the rest of the summary: the git sha or image of a deploy, the version "v5" of
+	// a rollback, or "DATABASE_URL, changed DEBUG" for config changes.
+	Subject string `json:"subject,omitempty"`
+}
+
+// String returns the event as it appears in the summary.
+func (e Event) String() string {
+	parts := []string{}
+	for _, part := range []string{e.Actor, e.Action, e.Subject} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Entry is a release with its parsed summary and creation time.
+type Entry struct {
+	Release api.Release `json:"release"`
+	Created time.Time   `json:"created"`
+	Events  []Event     `json:"events"`
+}
+
+// Has returns true if the release has an event of one of the kinds.
+func (e Entry) Has(kinds ...Kind) bool {
+	for _, event := range e.Events {
+		if hasKind(kinds, event.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter selects releases in History. Zero fields match every release.
+type Filter struct {
+	// Kinds are the kinds of events to keep.
+	Kinds []Kind
+	// Actor is the user whose events to keep. If Kinds is set, it applies to those events.
+	Actor string
+	// Since and Until bound the creation time of releases, Since included and Until excluded.
+	// Releases without a creation time are excluded if either is set.
+	Since time.Time
+	Until time.Time
+}
+
+// Match returns true if the filter selects the release.
+func (f Filter) Match(e Entry) bool {
+	if (!f.Since.IsZero() || !f.Until.IsZero()) && e.Created.IsZero() {
+		return false
+	}
+	if !f.Since.IsZero() && e.Created.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Created.Before(f.Until) {
+		return false
+	}
+	if len(f.Kinds) == 0 && f.Actor == "" {
+		return true
+	}
+
+	for _, event := range e.Events {
+		if f.Actor != "" && event.Actor != f.Actor {
+			continue
+		}
+		if len(f.Kinds) == 0 || hasKind(f.Kinds, event.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSummary parses a release summary into the changes it describes.
+// Parts of the summary that are not recognized are returned as KindUnknown events.
+func ParseSummary(summary string) []Event {
+	events := []Event{}
+	for _, clause := range strings.Split(summary, " and ") {
+		for _, part := range splitGlued(strings.TrimSpace(clause)) {
+			if part != "" {
+				events = append(events, parseClause(part))
+			}
+		}
+	}
+	return events
+}
+
+// ParseTime parses a time sent by the controller, such as the creation time of a release.
+func ParseTime(s string) (time.Time, error) {
+	t := dtime.Time{}
+	if err := t.UnmarshalText([]byte(s)); err != nil {
+		return time.Time{}, err
+	}
+	return *t.Time, nil
+}
+
+// NewEntry parses the summary and creation time of a release. If the creation time cannot be
+// parsed, the entry is returned with a zero Created along with the error.
+func NewEntry(release api.Release) (Entry, error) {
+	entry := Entry{Release: release, Events: ParseSummary(release.Summary)}
+	created, err := ParseTime(release.Created)
+	if err != nil {
+		return entry, fmt.Errorf("v%d: %w", release.Version, err)
+	}
+	entry.Created = created
+	return entry, nil
+}
+
+// ListAll lists all of an app's releases, newest first, fetching every page.
+func ListAll(c *deis.Client, appID string) ([]api.Release, error) {
+	u := fmt.Sprintf("/v2/apps/%s/releases/", appID)
+	body, reqErr := c.LimitedRequestAll(u)
+	if reqErr != nil && !deis.IsErrAPIMismatch(reqErr) {
+		return nil, reqErr
+	}
+
+	var releases []api.Release
+	if err := json.Unmarshal([]byte(body), &releases); err != nil {
+		return nil, err
+	}
+	return releases, reqErr
+}
+
+// History returns the releases of an app selected by the filter, newest first. Releases whose
+// creation time cannot be parsed have a zero Created, and are only selected by filters without
+// Since or Until.
+//
+// This example lists the config changes made by alice in the last week:
+//
+//    history, err := releases.History(<client>, "appname", releases.Filter{
+//    	Kinds: []releases.Kind{releases.KindConfig},
+//    	Actor: "alice",
+//    	Since: time.Now().AddDate(0, 0, -7),
+//    })
+func History(c *deis.Client, appID string, filter Filter) ([]Entry, error) {
+	releases, err := ListAll(c, appID)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return nil, err
+	}
+
+	history := []Entry{}
+	for _, release := range releases {
+		// An unparsable creation time leaves Created zero, which Match handles.
+		entry, _ := NewEntry(release)
+		if filter.Match(entry) {
+			history = append(history, entry)
+		}
+	}
+	return history, err
+}
+
+// actions are the verbs that follow the actor in summary clauses.
+var actions = []string{"created the initial release", "created initial release", "rolled back to",
+	"deployed", "added", "changed", "deleted", "removed", "mounted", "unmounted"}
+
+// gluedClauses are the clauses the controller appends to a summary without the " and "
+// separator, after the actor, as in "bob added DEBUGbob changed limits for memory".
+var gluedClauses = []string{" changed limits for ", " added healthcheck", " changed healthcheck",
+	" deleted healthcheck"}
+
+// splitGlued splits a clause where a glued clause of the same actor starts.
+func splitGlued(clause string) []string {
+	actor, _, _ := strings.Cut(clause, " ")
+	var parts []string
+	for actor != "" {
+		next := -1
+		for _, glued := range gluedClauses {
+			// The clause itself may start with a glued clause.
+			if i := strings.Index(clause[1:], actor+glued); i >= 0 && (next < 0 || i+1 < next) {
+				next = i + 1
+			}
+		}
+		if next < 0 {
+			break
+		}
+		parts = append(parts, clause[:next])
+		clause = clause[next:]
+	}
+	return append(parts, clause)
+}
+
+func hasKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClause(clause string) Event {
+	actor, rest, _ := strings.Cut(clause, " ")
+	event := Event{Kind: KindUnknown, Actor: actor, Subject: rest}
+
+	for _, action := range actions {
+		if rest != action && !strings.HasPrefix(rest, action+" ") {
+			continue
+		}
+		event.Action = action
+		event.Subject = strings.TrimSpace(strings.TrimPrefix(rest, action))
+		event.Kind = clauseKind(action, event.Subject)
+		break
+	}
+	return event
+}
+
+func clauseKind(action string, subject string) Kind {
+	switch action {
+	case "created the initial release", "created initial release":
+		return KindInitial
+	case "rolled back to":
+		return KindRollback
+	case "deployed":
+		return KindDeploy
+	case "mounted", "unmounted":
+		return KindVolume
+	}
+
+	switch {
+	case strings.HasPrefix(subject, "limits"):
+		return KindLimits
+	case strings.HasPrefix(subject, "healthcheck"):
+		return KindHealthcheck
+	case strings.HasPrefix(subject, "volume"):
+		return KindVolume
+	}
+	return KindConfig
+}
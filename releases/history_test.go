@@ -0,0 +1,143 @@
+package releases
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+)
+
+// historyFixtures have summaries as the controller writes them, which appends limits and
+// healthcheck changes without a separator.
+var historyFixtures = []string{
+	`{"version": 6, "summary": "bob changed DEBUGbob changed limits for memory", "created": "not a time"}`,
+	`{"version": 5, "summary": "bob changed limits for memory, cpu", "created": "2014-01-05T00:00:00UTC"}`,
+	`{"version": 4, "summary": "alice rolled back to v2", "created": "2014-01-04T00:00:00UTC"}`,
+	`{"version": 3, "summary": "alice deployed 5d6e7f8 and bob added DATABASE_URL, deleted DEBUG", "created": "2014-01-03T00:00:00UTC"}`,
+	`{"version": 2, "summary": "alice deployed 1a2b3c4", "created": "2014-01-02T00:00:00UTC"}`,
+	`{"version": 1, "summary": "alice created initial release", "created": "2014-01-01T00:00:00UTC"}`,
+}
+
+// historyHTTPServer serves the releases of example-go, truncated to the requested limit.
+type historyHTTPServer struct{}
+
+func (historyHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path != "/v2/apps/example-go/releases/" || req.Method != "GET" {
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+		return
+	}
+
+	results := historyFixtures
+	if limit, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && limit < len(results) {
+		results = results[:limit]
+	}
+	fmt.Fprintf(res, `{"count": %d, "next": null, "previous": null, "results": [%s]}`,
+		len(historyFixtures), strings.Join(results, ","))
+}
+
+func TestParseSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		summary  string
+		expected []Event
+	}{
+		{"alice deployed 1a2b3c4", []Event{{Kind: KindDeploy, Actor: "alice", Action: "deployed", Subject: "1a2b3c4"}}},
+		{"alice created initial release", []Event{{Kind: KindInitial, Actor: "alice", Action: "created initial release"}}},
+		{"bob added DATABASE_URL, changed DEBUG", []Event{
+			{Kind: KindConfig, Actor: "bob", Action: "added", Subject: "DATABASE_URL, changed DEBUG"}}},
+		{"alice rolled back to v5", []Event{{Kind: KindRollback, Actor: "alice", Action: "rolled back to", Subject: "v5"}}},
+		{"bob changed limits for memory", []Event{{Kind: KindLimits, Actor: "bob", Action: "changed", Subject: "limits for memory"}}},
+		{"bob added healthcheck for web", []Event{{Kind: KindHealthcheck, Actor: "bob", Action: "added", Subject: "healthcheck for web"}}},
+		{"bob deleted volume data", []Event{{Kind: KindVolume, Actor: "bob", Action: "deleted", Subject: "volume data"}}},
+		{"alice deployed deis/example-go:v2 and bob changed registry info", []Event{
+			{Kind: KindDeploy, Actor: "alice", Action: "deployed", Subject: "deis/example-go:v2"},
+			{Kind: KindConfig, Actor: "bob", Action: "changed", Subject: "registry info"}}},
+		{"bob added DATABASE_URL, changed DEBUGbob changed limits for memory, cpu", []Event{
+			{Kind: KindConfig, Actor: "bob", Action: "added", Subject: "DATABASE_URL, changed DEBUG"},
+			{Kind: KindLimits, Actor: "bob", Action: "changed", Subject: "limits for memory, cpu"}}},
+		{"bob deleted DEBUGbob added healthcheck for webbob changed limits for cpu", []Event{
+			{Kind: KindConfig, Actor: "bob", Action: "deleted", Subject: "DEBUG"},
+			{Kind: KindHealthcheck, Actor: "bob", Action: "added", Subject: "healthcheck for web"},
+			{Kind: KindLimits, Actor: "bob", Action: "changed", Subject: "limits for cpu"}}},
+		{"alice deployed 1a2b3c4 and bob changed tag envbob changed limits for memory", []Event{
+			{Kind: KindDeploy, Actor: "alice", Action: "deployed", Subject: "1a2b3c4"},
+			{Kind: KindConfig, Actor: "bob", Action: "changed", Subject: "tag env"},
+			{Kind: KindLimits, Actor: "bob", Action: "changed", Subject: "limits for memory"}}},
+		{"alice did something", []Event{{Kind: KindUnknown, Actor: "alice", Subject: "did something"}}},
+		{"", []Event{}},
+	}
+
+	for _, test := range tests {
+		actual := ParseSummary(test.summary)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%q: Expected %v, Got %v", test.summary, test.expected, actual)
+		}
+		if len(actual) == 1 && actual[0].String() != test.summary {
+			t.Errorf("Expected %q, Got %q", test.summary, actual[0].String())
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	handler := historyHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2014, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected []int
+	}{
+		{Filter{}, []int{6, 5, 4, 3, 2, 1}},
+		{Filter{Kinds: []Kind{KindDeploy}}, []int{3, 2}},
+		{Filter{Kinds: []Kind{KindDeploy, KindRollback}}, []int{4, 3, 2}},
+		{Filter{Kinds: []Kind{KindLimits}}, []int{6, 5}},
+		{Filter{Actor: "bob"}, []int{6, 5, 3}},
+		{Filter{Actor: "bob", Kinds: []Kind{KindDeploy}}, []int{}},
+		{Filter{Since: day(2), Until: day(4)}, []int{3, 2}},
+	}
+
+	for _, test := range tests {
+		history, err := History(client, "example-go", test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions := []int{}
+		for _, entry := range history {
+			versions = append(versions, entry.Release.Version)
+		}
+		if !reflect.DeepEqual(test.expected, versions) {
+			t.Errorf("%+v: Expected %v, Got %v", test.filter, test.expected, versions)
+		}
+	}
+
+	history, err := History(client, "example-go", Filter{Actor: "alice", Until: day(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || !history[0].Created.Equal(day(2)) || !history[0].Has(KindDeploy) || history[0].Has(KindConfig) {
+		t.Errorf("Unexpected history %+v", history)
+	}
+}
@@ -0,0 +1,190 @@
+package releases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/ps"
+)
+
+var (
+	// ErrNoRollbackTarget is returned when an app has no release to roll back to.
+	ErrNoRollbackTarget = errors.New("no release to roll back to")
+	// ErrUnsafeRollback is returned when a rollback would undo a volume removal or change the
+	// app's process types, and is not forced.
+	ErrUnsafeRollback = errors.New("unsafe rollback")
+)
+
+// RollbackOptions controls a planned rollback.
+type RollbackOptions struct {
+	// Version is the release version to roll back to. If it is 0, the target is the previous
+	// release, or the last deploy if ToLastDeploy is set.
+	Version int
+	// ToLastDeploy rolls back to the newest release that deployed a different build than the
+	// current one, skipping config-only releases, which keep the build of the deploy before them.
+	ToLastDeploy bool
+	// Force rolls back even if the plan has blockers.
+	Force bool
+	// DryRun only plans the rollback. Blockers are then reported by the plan, not as an error.
+	DryRun bool
+	// PollInterval is the interval between two listings of pods while waiting for them to run
+	// the rollback release. Defaults to ps.DefaultPollInterval.
+	PollInterval time.Duration
+	// Diff controls how the plan's diff shows secret config values.
+	Diff config.DiffOptions
+}
+
+// RollbackPlan describes what a rollback would restore.
+type RollbackPlan struct {
+	App string `json:"app"`
+	// Current is the app's latest release and Target is the release it rolls back to.
+	Current Snapshot `json:"current"`
+	Target  Snapshot `json:"target"`
+	// Diff is the change the rollback makes, from the current release to the target.
+	Diff Diff `json:"diff"`
+	// Undone are the releases newer than the target, whose changes the rollback undoes,
+	// newest first.
+	Undone []Entry `json:"undone"`
+	// Blockers are the reasons the rollback is unsafe, empty if it is safe.
+	Blockers []string `json:"blockers,omitempty"`
+}
+
+// Safe returns true if the rollback has no blockers.
+func (p RollbackPlan) Safe() bool {
+	return len(p.Blockers) == 0
+}
+
+// PlanRollback plans the rollback of an app without changing it: it finds the target release
+// and retrieves the build and config it would restore.
+//
+// A rollback is blocked if one of the undone releases removed a volume, since the target
+// release would mount it again, or if the target build has different process types than the
+// current one, since that starts or stops process types.
+func PlanRollback(c *deis.Client, appID string, opts RollbackOptions) (RollbackPlan, error) {
+	releases, err := ListAll(c, appID)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return RollbackPlan{}, err
+	}
+	if len(releases) < 2 {
+		return RollbackPlan{}, fmt.Errorf("%w: %s has %d release(s)", ErrNoRollbackTarget, appID, len(releases))
+	}
+
+	current := releases[0]
+	target := -1
+	for i, release := range releases[1:] {
+		switch {
+		case opts.Version > 0:
+			if release.Version == opts.Version {
+				target = i + 1
+			}
+		case opts.ToLastDeploy:
+			deploy := Entry{Events: ParseSummary(release.Summary)}.Has(KindDeploy)
+			if deploy && release.Build != "" && release.Build != current.Build {
+				target = i + 1
+			}
+		default:
+			target = i + 1
+		}
+		if target >= 0 {
+			break
+		}
+	}
+	if target < 0 {
+		if opts.Version > 0 {
+			return RollbackPlan{}, fmt.Errorf("%w: %s has no release v%d before v%d",
+				ErrNoRollbackTarget, appID, opts.Version, current.Version)
+		}
+		return RollbackPlan{}, fmt.Errorf("%w: %s has no deploy before its current build", ErrNoRollbackTarget, appID)
+	}
+
+	p := RollbackPlan{App: appID, Undone: []Entry{}}
+	for _, release := range releases[:target] {
+		// An unparsable creation time leaves Created zero, as in History.
+		entry, _ := NewEntry(release)
+		p.Undone = append(p.Undone, entry)
+	}
+
+	if p.Current, err = snapshot(c, appID, current); err != nil {
+		return RollbackPlan{}, err
+	}
+	if p.Target, err = snapshot(c, appID, releases[target]); err != nil {
+		return RollbackPlan{}, err
+	}
+	p.Diff = CompareSnapshots(p.Current, p.Target, opts.Diff)
+	p.Diff.App = appID
+	if p.Current.ConfigUnavailable || p.Target.ConfigUnavailable {
+		p.Diff = withSummaryChanges(p.Diff, releases)
+	}
+
+	for _, entry := range p.Undone {
+		for _, event := range entry.Events {
+			if removesVolume(event) {
+				p.Blockers = append(p.Blockers, fmt.Sprintf("v%d removed a volume: %s", entry.Release.Version, event))
+			}
+		}
+	}
+	for _, change := range p.Diff.Section(SectionProcfile) {
+		switch change.Type {
+		case config.Added:
+			p.Blockers = append(p.Blockers, fmt.Sprintf("process type %s would be added", change.Key))
+		case config.Removed:
+			p.Blockers = append(p.Blockers, fmt.Sprintf("process type %s would be removed", change.Key))
+		}
+	}
+
+	return p, nil
+}
+
+// SafeRollback plans the rollback of an app, rolls it back unless opts.DryRun is set, and waits
+// for every pod to run the release created by the rollback, until the context is done.
+// It returns the plan and the new release version, or 0 if the app was not rolled back.
+// Blocked rollbacks are not made and return an error matching ErrUnsafeRollback, unless
+// opts.Force is set.
+//
+// This example shows what rolling back to the last deploy would restore:
+//
+//    plan, _, err := releases.SafeRollback(ctx, <client>, "appname", releases.RollbackOptions{
+//    	ToLastDeploy: true,
+//    	DryRun:       true,
+//    })
+//    fmt.Printf("v%d\n%s", plan.Target.Release.Version, plan.Diff)
+func SafeRollback(ctx context.Context, c *deis.Client, appID string, opts RollbackOptions) (RollbackPlan, int, error) {
+	p, err := PlanRollback(c, appID, opts)
+	if err != nil {
+		return RollbackPlan{}, 0, err
+	}
+
+	if opts.DryRun {
+		return p, 0, nil
+	}
+	if !p.Safe() && !opts.Force {
+		return p, 0, fmt.Errorf("%w: rolling %s back to v%d: %s",
+			ErrUnsafeRollback, appID, p.Target.Release.Version, strings.Join(p.Blockers, "; "))
+	}
+
+	version, err := Rollback(c, appID, p.Target.Release.Version)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return p, 0, err
+	}
+
+	if _, err := ps.WaitForVersion(ctx, c, appID, version, opts.PollInterval); err != nil {
+		return p, version, err
+	}
+	return p, version, nil
+}
+
+func removesVolume(event Event) bool {
+	if event.Kind != KindVolume {
+		return false
+	}
+	switch event.Action {
+	case "deleted", "removed", "unmounted":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package releases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/config"
+)
+
+const rollbackReleasesFixture = `{"count": 5, "next": null, "previous": null, "results": [
+	{"app": "rollbacker", "version": 5, "build": "b2", "config": "c5", "summary": "bob added FOO", "created": "2014-01-05T00:00:00UTC"},
+	{"app": "rollbacker", "version": 4, "build": "b2", "config": "c3", "summary": "alice deployed 5d6e7f8", "created": "not a time"},
+	{"app": "rollbacker", "version": 3, "build": "b1", "config": "c3", "summary": "bob added DEBUG and bob deleted volume data", "created": "2014-01-03T00:00:00UTC"},
+	{"app": "rollbacker", "version": 2, "build": "b1", "config": "c2", "summary": "alice deployed 1a2b3c4", "created": "2014-01-02T00:00:00UTC"},
+	{"app": "rollbacker", "version": 1, "build": null, "config": "c1", "summary": "alice created initial release", "created": "2014-01-01T00:00:00UTC"}
+]}`
+
+var rollbackFixtures = map[string]string{
+	"/v2/apps/rollbacker/":           `{"id": "rollbacker", "owner": "test", "procfile_structure": {"web": "bin/web"}}`,
+	"/v2/apps/rollbacker/builds/b1/": `{"app": "rollbacker", "image": "deis/example-go:v1", "sha": "1a2b3c4", "procfile": {"web": "bin/web"}}`,
+	"/v2/apps/rollbacker/builds/b2/": `{"app": "rollbacker", "image": "deis/example-go:v2", "sha": "5d6e7f8",
+		"procfile": {"web": "bin/web", "worker": "bin/worker"}}`,
+	"/v2/apps/rollbacker/config/": `{"app": "rollbacker", "values": {"DEBUG": "true", "FOO": "bar"}, "uuid": "c5"}`,
+}
+
+// rollbackHTTPServer serves the releases of rollbacker and records the rollbacks it is asked for.
+type rollbackHTTPServer struct {
+	mu        sync.Mutex
+	rollbacks []string
+}
+
+func (s *rollbackHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	switch {
+	case req.URL.Path == "/v2/apps/rollbacker/releases/" && req.Method == "GET":
+		res.Write([]byte(rollbackReleasesFixture))
+	case req.URL.Path == "/v2/apps/rollbacker/releases/rollback/" && req.Method == "POST":
+		body, _ := ioutil.ReadAll(req.Body)
+		s.rollbacks = append(s.rollbacks, string(body))
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(`{"version": 6}`))
+	case req.URL.Path == "/v2/apps/rollbacker/pods/" && req.Method == "GET":
+		res.Write([]byte(`{"count": 1, "next": null, "previous": null, "results": [
+			{"release": "v6", "type": "web", "name": "web-6", "state": "up", "started": "2016-02-13T00:47:52"}]}`))
+	case rollbackFixtures[req.URL.Path] != "" && req.Method == "GET":
+		res.Write([]byte(rollbackFixtures[req.URL.Path]))
+	default:
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&rollbackHTTPServer{})
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts     RollbackOptions
+		target   int
+		undone   int
+		blockers []string
+	}{
+		{RollbackOptions{}, 4, 1, nil},
+		{RollbackOptions{ToLastDeploy: true}, 2, 3, []string{"v3 removed a volume: bob deleted volume data",
+			"process type worker would be removed"}},
+		{RollbackOptions{Version: 2}, 2, 3, []string{"v3 removed a volume: bob deleted volume data",
+			"process type worker would be removed"}},
+	}
+
+	for _, test := range tests {
+		plan, err := PlanRollback(client, "rollbacker", test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plan.Current.Release.Version != 5 || plan.Target.Release.Version != test.target || len(plan.Undone) != test.undone {
+			t.Errorf("%+v: Expected v5 to v%d undoing %d releases, Got v%d to v%d undoing %d", test.opts, test.target,
+				test.undone, plan.Current.Release.Version, plan.Target.Release.Version, len(plan.Undone))
+		}
+		if !reflect.DeepEqual(test.blockers, plan.Blockers) || plan.Safe() != (test.blockers == nil) {
+			t.Errorf("%+v: Expected blockers %v, Got %v", test.opts, test.blockers, plan.Blockers)
+		}
+	}
+
+	plan, err := PlanRollback(client, "rollbacker", RollbackOptions{ToLastDeploy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []config.Change{
+		{Section: config.SectionValues, Key: "DEBUG", Type: config.Removed, Old: config.Mask, Masked: true},
+		{Section: config.SectionValues, Key: "FOO", Type: config.Removed, Old: config.Mask, Masked: true},
+	}
+	if actual := plan.Diff.Section(config.SectionValues); !reflect.DeepEqual(expected, actual) || !plan.Diff.ConfigFromSummaries {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+	if plan.Target.Build.Image != "deis/example-go:v1" {
+		t.Errorf("Expected the target build to be restored, Got %+v", plan.Target.Build)
+	}
+	if undone := plan.Undone[1]; undone.Release.Version != 4 || !undone.Created.IsZero() || !undone.Has(KindDeploy) {
+		t.Errorf("Expected v4 to be undone without a creation time, Got %+v", undone)
+	}
+
+	for _, version := range []int{5, 7} {
+		if _, err := PlanRollback(client, "rollbacker", RollbackOptions{Version: version}); !errors.Is(err, ErrNoRollbackTarget) {
+			t.Errorf("v%d: Expected %v, Got %v", version, ErrNoRollbackTarget, err)
+		}
+	}
+}
+
+func TestSafeRollback(t *testing.T) {
+	t.Parallel()
+
+	handler := &rollbackHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	_, version, err := SafeRollback(ctx, client, "rollbacker", RollbackOptions{ToLastDeploy: true})
+	if !errors.Is(err, ErrUnsafeRollback) || version != 0 || !strings.Contains(err.Error(), "worker") {
+		t.Errorf("Expected %v, Got v%d %v", ErrUnsafeRollback, version, err)
+	}
+
+	plan, version, err := SafeRollback(ctx, client, "rollbacker", RollbackOptions{ToLastDeploy: true, DryRun: true})
+	if err != nil || version != 0 || plan.Safe() {
+		t.Errorf("Expected an unsafe plan and no rollback, Got v%d %v %+v", version, err, plan)
+	}
+
+	_, version, err = SafeRollback(ctx, client, "rollbacker", RollbackOptions{PollInterval: time.Millisecond})
+	if err != nil || version != 6 {
+		t.Errorf("Expected v6, Got v%d %v", version, err)
+	}
+
+	_, version, err = SafeRollback(ctx, client, "rollbacker", RollbackOptions{ToLastDeploy: true, Force: true,
+		PollInterval: time.Millisecond})
+	if err != nil || version != 6 {
+		t.Errorf("Expected v6, Got v%d %v", version, err)
+	}
+
+	expected := []string{`{"version":4}`, `{"version":2}`}
+	if !reflect.DeepEqual(expected, handler.rollbacks) {
+		t.Errorf("Expected rollbacks %v, Got %v", expected, handler.rollbacks)
+	}
+}
@@ -0,0 +1,128 @@
+package releases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+// DefaultPollInterval is the interval between two listings of an app's releases in Watch.
+const DefaultPollInterval = 5 * time.Second
+
+// ErrStopWatch can be returned by a Watch callback to stop watching without an error.
+var ErrStopWatch = errors.New("stop watching releases")
+
+// WatchOptions controls Watch and WaitForRelease.
+type WatchOptions struct {
+	// After is the version after which releases are new. If it is 0, it is the app's latest
+	// version when the watch starts.
+	After int
+	// Kinds, if set, only keeps releases with an event of one of the kinds, see ParseSummary.
+	Kinds []Kind
+	// PollInterval is the interval between two listings of the app's releases.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Timeout, if set, bounds the watch in addition to the context.
+	Timeout time.Duration
+}
+
+// Watch polls an app's releases and calls f with each new release, oldest first, until the
+// context is done, the timeout expires or f returns an error. If f returns ErrStopWatch, Watch
+// returns nil; otherwise it returns the error of f, of listing releases, or one that wraps the
+// context's error.
+func Watch(ctx context.Context, c *deis.Client, appID string, opts WatchOptions, f func(api.Release) error) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	last := opts.After
+	if last <= 0 {
+		latest, _, err := List(c, appID, 1)
+		if err != nil && !deis.IsErrAPIMismatch(err) {
+			return err
+		}
+		if len(latest) > 0 {
+			last = latest[0].Version
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := newReleases(c, appID, last)
+		if err != nil {
+			return err
+		}
+
+		for _, release := range created {
+			last = release.Version
+			if len(opts.Kinds) > 0 && !(Entry{Events: ParseSummary(release.Summary)}).Has(opts.Kinds...) {
+				continue
+			}
+			if err := f(release); err == ErrStopWatch {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("watching releases of %s after v%d: %w", appID, last, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForRelease blocks until an app has a release newer than opts.After, of one of opts.Kinds
+// if set, and returns it.
+//
+// This example waits for the release of a build triggered by a git push:
+//
+//    latest, _, err := releases.List(<client>, "appname", 1)
+//    // git push
+//    release, err := releases.WaitForRelease(ctx, <client>, "appname", releases.WatchOptions{
+//    	After:   latest[0].Version,
+//    	Kinds:   []releases.Kind{releases.KindDeploy},
+//    	Timeout: 10 * time.Minute,
+//    })
+func WaitForRelease(ctx context.Context, c *deis.Client, appID string, opts WatchOptions) (api.Release, error) {
+	var found api.Release
+	err := Watch(ctx, c, appID, opts, func(release api.Release) error {
+		found = release
+		return ErrStopWatch
+	})
+	return found, err
+}
+
+// newReleases returns an app's releases newer than version after, oldest first.
+func newReleases(c *deis.Client, appID string, after int) ([]api.Release, error) {
+	releases, count, err := List(c, appID, 100)
+	if err != nil && !deis.IsErrAPIMismatch(err) {
+		return nil, err
+	}
+	if count > len(releases) && len(releases) > 0 && releases[len(releases)-1].Version > after {
+		if releases, err = ListAll(c, appID); err != nil && !deis.IsErrAPIMismatch(err) {
+			return nil, err
+		}
+	}
+
+	created := []api.Release{}
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].Version > after {
+			created = append(created, releases[i])
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,127 @@
+package releases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+)
+
+var watchSummaries = []string{"alice created initial release", "alice added FOO", "alice deployed 1a2b3c4",
+	"alice scaled web=2"}
+
+// watchHTTPServer serves the releases of example-go, creating a new one after each listing
+// until every summary is used.
+type watchHTTPServer struct {
+	mu      sync.Mutex
+	version int
+}
+
+func (s *watchHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	if req.URL.Path != "/v2/apps/example-go/releases/" || req.Method != "GET" {
+		fmt.Printf("Unrecognized URL %s\n", req.URL)
+		res.WriteHeader(http.StatusNotFound)
+		res.Write(nil)
+		return
+	}
+
+	results := []string{}
+	for v := s.version; v > 0; v-- {
+		results = append(results, fmt.Sprintf(`{"app": "example-go", "version": %d, "summary": "%s",
+			"created": "2014-01-01T00:00:00UTC"}`, v, watchSummaries[v-1]))
+	}
+	fmt.Fprintf(res, `{"count": %d, "next": null, "previous": null, "results": [%s]}`,
+		len(results), strings.Join(results, ","))
+
+	if s.version < len(watchSummaries) {
+		s.version++
+	}
+}
+
+func newWatchClient(t *testing.T) (*deis.Client, func()) {
+	server := httptest.NewServer(&watchHTTPServer{version: 1})
+	client, err := deis.New(false, server.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	client, stop := newWatchClient(t)
+	defer stop()
+
+	versions := []int{}
+	err := Watch(context.Background(), client, "example-go", WatchOptions{PollInterval: time.Millisecond},
+		func(release api.Release) error {
+			versions = append(versions, release.Version)
+			if release.Version == 4 {
+				return ErrStopWatch
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{2, 3, 4}; !reflect.DeepEqual(expected, versions) {
+		t.Errorf("Expected %v, Got %v", expected, versions)
+	}
+
+	expected := errors.New("deploy failed")
+	err = Watch(context.Background(), client, "example-go", WatchOptions{After: 1, PollInterval: time.Millisecond},
+		func(release api.Release) error {
+			return expected
+		})
+	if err != expected {
+		t.Errorf("Expected %v, Got %v", expected, err)
+	}
+}
+
+func TestWaitForRelease(t *testing.T) {
+	t.Parallel()
+
+	client, stop := newWatchClient(t)
+	defer stop()
+
+	release, err := WaitForRelease(context.Background(), client, "example-go", WatchOptions{
+		Kinds:        []Kind{KindDeploy},
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Version != 3 {
+		t.Errorf("Expected v3, Got %+v", release)
+	}
+
+	_, err = WaitForRelease(context.Background(), client, "example-go", WatchOptions{
+		After:        4,
+		PollInterval: time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, Got %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = WaitForRelease(ctx, client, "example-go", WatchOptions{After: 4}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, Got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned when a cron expression cannot be parsed.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// descriptors are the predefined schedules accepted in place of a five field expression.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fields = []field{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, monthNames},
+	{"day of week", 0, 7, dayNames},
+}
+
+// Schedule is a parsed cron expression. Each field is a bitmask of the values it matches.
+type Schedule struct {
+	spec     string
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool
+	dowStar  bool
+	location *time.Location
+}
+
+// ParseSchedule parses a standard five field cron expression
+// (minute, hour, day of month, month, day of week). Fields accept lists (1,15),
+// ranges (1-5), steps (*/15, 0-30/10) and three letter month and day names.
+// The descriptors @yearly, @monthly, @weekly, @daily, @midnight and @hourly are also
+// accepted. As in cron, if both day of month and day of week are restricted,
+// a time matches when either of them matches.
+func ParseSchedule(spec string) (Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("%w %q: expected %d fields, got %d",
+			ErrInvalidSchedule, spec, len(fields), len(parts))
+	}
+
+	masks := make([]uint64, len(fields))
+	for i, f := range fields {
+		mask, err := parseField(parts[i], f)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%w %q: %s", ErrInvalidSchedule, spec, err)
+		}
+		masks[i] = mask
+	}
+
+	// Both 0 and 7 mean sunday.
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+		masks[4] &^= 1 << 7
+	}
+
+	return Schedule{
+		spec:    spec,
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// In returns a copy of the schedule that is evaluated in the given location.
+// By default, schedules are evaluated in the location of the time passed to Next.
+func (s Schedule) In(loc *time.Location) Schedule {
+	s.location = loc
+	return s
+}
+
+// String returns the expression the schedule was parsed from.
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time strictly after t that matches the schedule.
+// It returns the zero time if no match exists within the next five years,
+// which can only happen for impossible dates such as the 31st of February.
+// Times that fall in a daylight saving gap do not exist and are skipped, while times in the
+// hour repeated when clocks go back match twice.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.location != nil {
+		t = t.In(s.location)
+	}
+	loc := t.Location()
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchDay(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			next = nextHour(t)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+
+		// time.Date normalizes a wall clock in a daylight saving gap, such as midnight in zones
+		// that spring forward at midnight, to a time that can be before t.
+		if !next.After(t) {
+			next = nextHour(t)
+		}
+		t = next
+	}
+
+	return time.Time{}
+}
+
+// nextHour returns the start of the hour after t, in elapsed time so that it always moves
+// forward across daylight saving transitions.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+func (s Schedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var mask uint64
+
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangeExpr = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", f.name, item)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangeExpr == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field: %q", f.name, item)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rangeExpr, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// A step on a single value runs to the end of the range, as in "5/15".
+			if step > 1 {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+
+	return mask, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value in %s field: %q", f.name, s)
+	}
+	return v, nil
+}
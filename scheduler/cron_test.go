@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type nextExpected struct {
+	Spec     string
+	From     string
+	Expected string
+}
+
+func TestScheduleNext(t *testing.T) {
+	t.Parallel()
+
+	tests := []nextExpected{
+		{"* * * * *", "2016-02-13T00:47:52Z", "2016-02-13T00:48:00Z"},
+		{"*/15 * * * *", "2016-02-13T00:47:00Z", "2016-02-13T01:00:00Z"},
+		{"0 20 * * mon-fri", "2016-02-12T20:00:00Z", "2016-02-15T20:00:00Z"},
+		{"30 7 * * 1-5", "2016-02-13T00:00:00Z", "2016-02-15T07:30:00Z"},
+		{"0 0 1 jan *", "2016-02-13T00:00:00Z", "2017-01-01T00:00:00Z"},
+		{"0 0 29 2 *", "2016-03-01T00:00:00Z", "2020-02-29T00:00:00Z"},
+		{"0 12 13 * 0", "2016-02-10T00:00:00Z", "2016-02-13T12:00:00Z"},
+		{"0 12 * * 7", "2016-02-13T13:00:00Z", "2016-02-14T12:00:00Z"},
+		{"5/20 1,2 * * *", "2016-02-13T01:30:00Z", "2016-02-13T01:45:00Z"},
+		{"@daily", "2016-02-13T00:47:52Z", "2016-02-14T00:00:00Z"},
+		{"@hourly", "2016-02-13T00:47:52Z", "2016-02-13T01:00:00Z"},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.Spec)
+		if err != nil {
+			t.Fatalf("%s: %v", test.Spec, err)
+		}
+
+		from, _ := time.Parse(time.RFC3339, test.From)
+		expected, _ := time.Parse(time.RFC3339, test.Expected)
+
+		if actual := s.Next(from); !actual.Equal(expected) {
+			t.Errorf("%s from %s: Expected %v, Got %v", test.Spec, test.From, expected, actual)
+		}
+	}
+}
+
+func TestScheduleNextInLocation(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, err := ParseSchedule("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2016, 2, 13, 5, 0, 0, 0, time.UTC)
+	expected := time.Date(2016, 2, 13, 6, 0, 0, 0, time.UTC)
+
+	if actual := s.In(loc).Next(from); !actual.Equal(expected) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestScheduleNextDST(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		spec     string
+		from     time.Time
+		expected []time.Time
+	}{
+		// 02:30 does not exist on the 8th of March 2026, clocks go from 02:00 to 03:00.
+		{"30 2 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), []time.Time{
+			time.Date(2026, 3, 9, 2, 30, 0, 0, loc),
+		}},
+		{"0 * * * *", time.Date(2026, 3, 8, 0, 30, 0, 0, loc), []time.Time{
+			time.Date(2026, 3, 8, 1, 0, 0, 0, loc),
+			time.Date(2026, 3, 8, 3, 0, 0, 0, loc),
+		}},
+		// 01:30 happens twice on the 1st of November 2026, clocks go from 02:00 back to 01:00.
+		{"30 1 * * *", time.Date(2026, 11, 1, 0, 0, 0, 0, loc), []time.Time{
+			time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC),
+			time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC),
+			time.Date(2026, 11, 2, 1, 30, 0, 0, loc),
+		}},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		from := test.from
+		for _, expected := range test.expected {
+			actual := s.Next(from)
+			if !actual.Equal(expected) {
+				t.Errorf("%s from %v: Expected %v, Got %v", test.spec, from, expected, actual)
+				break
+			}
+			from = actual
+		}
+	}
+
+	// Midnight does not exist on the 4th of November 2018 in Sao Paulo.
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := ParseSchedule("0 12 5 * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2018, 11, 5, 12, 0, 0, 0, saoPaulo)
+	if actual := s.Next(time.Date(2018, 11, 3, 12, 0, 0, 0, saoPaulo)); !actual.Equal(expected) {
+		t.Errorf("Expected %v, Got %v", expected, actual)
+	}
+}
+
+func TestScheduleImpossible(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := s.Next(time.Now()); !actual.IsZero() {
+		t.Errorf("Expected zero time, Got %v", actual)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@reboot",
+	} {
+		if _, err := ParseSchedule(spec); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%q: Expected %v, Got %v", spec, ErrInvalidSchedule, err)
+		}
+	}
+}
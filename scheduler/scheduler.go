@@ -0,0 +1,457 @@
+// Package scheduler runs time based scaling and maintenance rules against apps.
+//
+// Rules are described with cron expressions and are executed in-process by a Scheduler.
+// Each rule can scale an app's process types, toggle maintenance mode and toggle whether
+// the app is routable.
+//
+// This example scales down an app every weekday evening and brings it back in the morning:
+//
+//    s, err := scheduler.New(client, []scheduler.Rule{
+//        {Name: "night", App: "example-go", Schedule: "0 20 * * mon-fri",
+//            Scale: map[string]int{"web": 0}},
+//        {Name: "morning", App: "example-go", Schedule: "0 7 * * mon-fri",
+//            Scale: map[string]int{"web": 2}},
+//    })
+//    if err != nil {
+//        log.Fatal(err)
+//    }
+//    s.Jitter = 2 * time.Minute
+//    log.Fatal(s.Run(ctx))
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/api"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/appsettings"
+	"github.com/trilogy-group/devgraph-eyk-controller-sdk-go/ps"
+)
+
+var (
+	// ErrNoAction is returned when a rule does not scale or change any setting.
+	ErrNoAction = errors.New("rule must scale processes or change maintenance or routable")
+	// ErrMissingApp is returned when a rule does not name an app.
+	ErrMissingApp = errors.New("rule must name an app")
+	// ErrDuplicateRule is returned when two rules share the same name.
+	ErrDuplicateRule = errors.New("rule names must be unique")
+)
+
+// MissedRunPolicy decides what happens to a run that could not start on time,
+// for example because the host was suspended or a previous run was still executing.
+type MissedRunPolicy int
+
+const (
+	// SkipMissed records missed runs as skipped and waits for the next scheduled time.
+	// At most MaxSkippedEntries entries are recorded for the runs missed at once.
+	SkipMissed MissedRunPolicy = iota
+	// RunOnceMissed executes a rule once for any number of consecutive missed runs.
+	RunOnceMissed
+)
+
+// DefaultMissedRunTolerance is how late a run may start before it is considered missed.
+const DefaultMissedRunTolerance = time.Minute
+
+// MaxSkippedEntries is the number of entries recorded for the runs of a rule missed at once,
+// for example while the host was suspended. The last entry stands for the remaining runs.
+const MaxSkippedEntries = 10
+
+// DefaultLogSize is the default number of entries kept in a scheduler's execution log.
+const DefaultLogSize = 1000
+
+// Rule is a scheduled change to an app. At least one of Scale, Maintenance or Routable must be set.
+type Rule struct {
+	// Name identifies the rule in the execution log. It defaults to the app name and schedule.
+	Name string
+	// App is the app the rule applies to.
+	App string
+	// Schedule is a cron expression, see ParseSchedule.
+	Schedule string
+	// Scale is passed to ps.Scale. The key is the process type and the value the number of replicas.
+	Scale map[string]int
+	// Maintenance enables or disables maintenance mode when set.
+	Maintenance *bool
+	// Routable enables or disables routing to the app when set.
+	Routable *bool
+}
+
+func (r Rule) validate() error {
+	if r.App == "" {
+		return ErrMissingApp
+	}
+	if len(r.Scale) == 0 && r.Maintenance == nil && r.Routable == nil {
+		return ErrNoAction
+	}
+	return nil
+}
+
+// actions describes the changes a rule makes, in the order they are applied.
+func (r Rule) actions() []string {
+	var actions []string
+	if r.Maintenance != nil && *r.Maintenance {
+		actions = append(actions, "maintenance=true")
+	}
+	if len(r.Scale) > 0 {
+		types := make([]string, 0, len(r.Scale))
+		for t := range r.Scale {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			actions = append(actions, fmt.Sprintf("scale %s=%d", t, r.Scale[t]))
+		}
+	}
+	if r.Routable != nil {
+		actions = append(actions, fmt.Sprintf("routable=%t", *r.Routable))
+	}
+	if r.Maintenance != nil && !*r.Maintenance {
+		actions = append(actions, "maintenance=false")
+	}
+	return actions
+}
+
+// Status is the outcome of a scheduled run.
+type Status string
+
+const (
+	// StatusSucceeded means all of the rule's changes were applied.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed means applying the rule returned an error.
+	StatusFailed Status = "failed"
+	// StatusSkipped means the run was missed and dropped by the missed run policy.
+	StatusSkipped Status = "skipped"
+	// StatusDryRun means the run was due but the scheduler is in dry-run mode.
+	StatusDryRun Status = "dry-run"
+)
+
+// Entry is a record of a scheduled run in the execution log.
+type Entry struct {
+	Rule      string
+	App       string
+	Scheduled time.Time
+	Started   time.Time
+	Finished  time.Time
+	// Missed is true if the run started later than the missed run tolerance.
+	Missed bool
+	// Skipped is the number of missed runs a skipped entry stands for, more than one for the
+	// last entry recorded when more than MaxSkippedEntries runs are missed at once.
+	Skipped int
+	Status  Status
+	Actions []string
+	Err     error
+}
+
+// String displays the Entry in a readable format.
+func (e Entry) String() string {
+	s := fmt.Sprintf("%s %s (%s) %s: %s", e.Scheduled.Format(time.RFC3339), e.Rule, e.App,
+		e.Status, strings.Join(e.Actions, ", "))
+	if e.Skipped > 1 {
+		s += fmt.Sprintf(" (%d runs)", e.Skipped)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+type job struct {
+	rule     Rule
+	schedule Schedule
+	next     time.Time
+	fireAt   time.Time
+}
+
+// Scheduler executes rules at their scheduled times.
+// Its exported fields must not be modified after Run has been called.
+type Scheduler struct {
+	// Client is used to apply the rules.
+	Client *deis.Client
+	// Location is the time zone schedules are evaluated in. It defaults to time.Local.
+	Location *time.Location
+	// Jitter delays each run by a random duration up to its value, so that rules scheduled
+	// at the same time don't hit the controller together.
+	Jitter time.Duration
+	// MissedRunPolicy decides what happens to runs that start too late.
+	MissedRunPolicy MissedRunPolicy
+	// MissedRunTolerance is how late a run may start before it is considered missed.
+	// It defaults to DefaultMissedRunTolerance.
+	MissedRunTolerance time.Duration
+	// DryRun logs the actions rules would take without applying them.
+	DryRun bool
+	// LogSize is the number of entries kept in the execution log. It defaults to DefaultLogSize.
+	LogSize int
+	// OnEntry is called, if set, every time an entry is added to the execution log.
+	OnEntry func(Entry)
+
+	jobs []*job
+	// mu guards the execution log and the schedule of the jobs, which Next reads while Run
+	// updates them.
+	mu    sync.Mutex
+	log   []Entry
+	now   func() time.Time
+	rand  *rand.Rand
+	after func(time.Duration) <-chan time.Time
+}
+
+// New creates a scheduler for a set of rules. It returns an error if a rule is invalid.
+func New(c *deis.Client, rules []Rule) (*Scheduler, error) {
+	s := &Scheduler{
+		Client: c,
+		now:    time.Now,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		after:  time.After,
+	}
+
+	names := make(map[string]bool)
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		sched, err := ParseSchedule(r.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		if r.Name == "" {
+			r.Name = r.App + " " + r.Schedule
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, ErrDuplicateRule)
+		}
+		names[r.Name] = true
+		s.jobs = append(s.jobs, &job{rule: r, schedule: sched})
+	}
+
+	return s, nil
+}
+
+// Run executes the rules until the context is cancelled, and then returns the context's error.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.start(s.now())
+
+	for {
+		wait := s.nextFire().Sub(s.now())
+		if wait < 0 {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.after(wait):
+			s.runDue(s.now())
+		}
+	}
+}
+
+// Entries returns a copy of the execution log, oldest entry first.
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, len(s.log))
+	copy(entries, s.log)
+	return entries
+}
+
+// Next returns the next scheduled time of each rule, keyed by rule name.
+func (s *Scheduler) Next() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	next := make(map[string]time.Time, len(s.jobs))
+	for _, j := range s.jobs {
+		if j.next.IsZero() {
+			next[j.rule.Name] = j.schedule.In(s.location()).Next(now)
+		} else {
+			next[j.rule.Name] = j.next
+		}
+	}
+	return next
+}
+
+func (s *Scheduler) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+func (s *Scheduler) tolerance() time.Duration {
+	if s.MissedRunTolerance <= 0 {
+		return DefaultMissedRunTolerance
+	}
+	return s.MissedRunTolerance
+}
+
+func (s *Scheduler) start(now time.Time) {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.schedule = j.schedule.In(s.location())
+	}
+	s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		s.schedule(j, now)
+	}
+}
+
+// schedule sets the next run of a job to the first scheduled time after t.
+func (s *Scheduler) schedule(j *job, t time.Time) {
+	next := j.schedule.Next(t)
+	fireAt := next
+	if s.Jitter > 0 {
+		fireAt = fireAt.Add(time.Duration(s.rand.Int63n(int64(s.Jitter))))
+	}
+
+	s.mu.Lock()
+	j.next, j.fireAt = next, fireAt
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) nextFire() time.Time {
+	var next time.Time
+	for _, j := range s.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || j.fireAt.Before(next) {
+			next = j.fireAt
+		}
+	}
+	if next.IsZero() {
+		// Nothing is scheduled, check back in a day in case of clock changes.
+		return s.now().Add(24 * time.Hour)
+	}
+	return next
+}
+
+// runDue executes every job whose time has come and reschedules it.
+func (s *Scheduler) runDue(now time.Time) {
+	for _, j := range s.jobs {
+		if j.next.IsZero() || j.fireAt.After(now) {
+			continue
+		}
+
+		missed := now.Sub(j.fireAt) > s.tolerance()
+		if missed && s.MissedRunPolicy == SkipMissed {
+			s.skip(j, now)
+		} else {
+			s.execute(j, now, missed)
+		}
+
+		s.schedule(j, now)
+	}
+}
+
+// skip records the runs of a job missed until now as skipped. Past MaxSkippedEntries, the
+// remaining runs are counted in the last entry.
+func (s *Scheduler) skip(j *job, now time.Time) {
+	var entries []Entry
+	for t := j.next; !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+		if len(entries) == MaxSkippedEntries {
+			entries[len(entries)-1].Skipped++
+			continue
+		}
+		entries = append(entries, Entry{
+			Rule:      j.rule.Name,
+			App:       j.rule.App,
+			Scheduled: t,
+			Started:   now,
+			Finished:  now,
+			Missed:    true,
+			Skipped:   1,
+			Status:    StatusSkipped,
+			Actions:   j.rule.actions(),
+		})
+	}
+
+	for _, e := range entries {
+		s.record(e)
+	}
+}
+
+func (s *Scheduler) execute(j *job, now time.Time, missed bool) {
+	e := Entry{
+		Rule:      j.rule.Name,
+		App:       j.rule.App,
+		Scheduled: j.next,
+		Started:   now,
+		Missed:    missed,
+		Actions:   j.rule.actions(),
+	}
+
+	if s.DryRun {
+		e.Status = StatusDryRun
+	} else if err := apply(s.Client, j.rule); err != nil {
+		e.Status = StatusFailed
+		e.Err = err
+	} else {
+		e.Status = StatusSucceeded
+	}
+
+	e.Finished = s.now()
+	s.record(e)
+}
+
+// apply makes the changes described by a rule. Maintenance is enabled before scaling
+// and disabled after it, so that users see the maintenance page while processes change.
+func apply(c *deis.Client, r Rule) error {
+	if r.Maintenance != nil && *r.Maintenance {
+		if err := setSettings(c, r.App, api.AppSettings{Maintenance: r.Maintenance}); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Scale) > 0 {
+		if err := ps.Scale(c, r.App, r.Scale); err != nil && !deis.IsErrAPIMismatch(err) {
+			return err
+		}
+	}
+
+	if r.Routable != nil {
+		if err := setSettings(c, r.App, api.AppSettings{Routable: r.Routable}); err != nil {
+			return err
+		}
+	}
+
+	if r.Maintenance != nil && !*r.Maintenance {
+		if err := setSettings(c, r.App, api.AppSettings{Maintenance: r.Maintenance}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setSettings(c *deis.Client, app string, settings api.AppSettings) error {
+	if _, err := appsettings.Set(c, app, settings); err != nil && !deis.IsErrAPIMismatch(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Scheduler) record(e Entry) {
+	size := s.LogSize
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+
+	s.mu.Lock()
+	s.log = append(s.log, e)
+	if len(s.log) > size {
+		s.log = s.log[len(s.log)-size:]
+	}
+	s.mu.Unlock()
+
+	if s.OnEntry != nil {
+		s.OnEntry(e)
+	}
+}
@@ -0,0 +1,331 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	deis "github.com/trilogy-group/devgraph-eyk-controller-sdk-go"
+)
+
+const appSettingsFixture string = `
+{
+    "owner": "test",
+    "app": "example-go",
+    "created": "2014-01-01T00:00:00UTC",
+    "updated": "2014-01-01T00:00:00UTC",
+    "uuid": "de1bf5b5-4a72-4f94-a10c-d2a3741cdf75"
+}
+`
+
+type fakeHTTPServer struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeHTTPServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("DEIS_API_VERSION", deis.APIVersion)
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		fmt.Println(err)
+		res.WriteHeader(http.StatusInternalServerError)
+		res.Write(nil)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req.Method+" "+req.URL.Path+" "+string(body))
+	f.mu.Unlock()
+
+	if req.URL.Path == "/v2/apps/example-go/scale/" && req.Method == "POST" {
+		res.WriteHeader(http.StatusNoContent)
+		res.Write(nil)
+		return
+	}
+
+	if req.URL.Path == "/v2/apps/example-go/settings/" && req.Method == "POST" {
+		res.WriteHeader(http.StatusCreated)
+		res.Write([]byte(appSettingsFixture))
+		return
+	}
+
+	fmt.Printf("Unrecognized URL %s\n", req.URL)
+	res.WriteHeader(http.StatusNotFound)
+	res.Write(nil)
+}
+
+var (
+	trueVar  = true
+	falseVar = false
+)
+
+func newTestScheduler(t *testing.T, url string, rules []Rule, now time.Time) *Scheduler {
+	client, err := deis.New(false, url, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(client, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Location = time.UTC
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Rules    []Rule
+		Expected error
+	}{
+		{[]Rule{{Schedule: "* * * * *", Routable: &trueVar}}, ErrMissingApp},
+		{[]Rule{{App: "example-go", Schedule: "* * * * *"}}, ErrNoAction},
+		{[]Rule{{App: "example-go", Schedule: "* *", Routable: &trueVar}}, ErrInvalidSchedule},
+		{[]Rule{
+			{Name: "a", App: "example-go", Schedule: "* * * * *", Routable: &trueVar},
+			{Name: "a", App: "example-go", Schedule: "0 * * * *", Routable: &falseVar},
+		}, ErrDuplicateRule},
+	}
+
+	for _, test := range tests {
+		if _, err := New(nil, test.Rules); !errors.Is(err, test.Expected) {
+			t.Errorf("Expected %v, Got %v", test.Expected, err)
+		}
+	}
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	t.Parallel()
+
+	handler := &fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	start := time.Date(2016, 2, 12, 19, 30, 0, 0, time.UTC)
+	s := newTestScheduler(t, server.URL, []Rule{
+		{
+			Name:        "night",
+			App:         "example-go",
+			Schedule:    "0 20 * * mon-fri",
+			Scale:       map[string]int{"web": 0},
+			Maintenance: &trueVar,
+		},
+	}, start)
+	s.start(start)
+
+	s.runDue(start)
+	if len(handler.requests) != 0 {
+		t.Fatalf("Expected no requests before the scheduled time, Got %v", handler.requests)
+	}
+
+	fire := time.Date(2016, 2, 12, 20, 0, 10, 0, time.UTC)
+	s.now = func() time.Time { return fire }
+	s.runDue(fire)
+
+	expected := []string{
+		`POST /v2/apps/example-go/settings/ {"maintenance":true}`,
+		`POST /v2/apps/example-go/scale/ {"web":0}`,
+	}
+	if !reflect.DeepEqual(expected, handler.requests) {
+		t.Errorf("Expected %v, Got %v", expected, handler.requests)
+	}
+
+	entries := s.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, Got %v", entries)
+	}
+	if entries[0].Status != StatusSucceeded || entries[0].Missed {
+		t.Errorf("Expected a successful run, Got %v", entries[0])
+	}
+
+	nextExpected := time.Date(2016, 2, 15, 20, 0, 0, 0, time.UTC)
+	if next := s.Next()["night"]; !next.Equal(nextExpected) {
+		t.Errorf("Expected %v, Got %v", nextExpected, next)
+	}
+}
+
+func TestSchedulerDryRun(t *testing.T) {
+	t.Parallel()
+
+	handler := &fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	start := time.Date(2016, 2, 12, 19, 59, 0, 0, time.UTC)
+	s := newTestScheduler(t, server.URL, []Rule{
+		{App: "example-go", Schedule: "0 20 * * *", Routable: &falseVar},
+	}, start)
+	s.DryRun = true
+
+	var logged []Entry
+	s.OnEntry = func(e Entry) { logged = append(logged, e) }
+
+	s.start(start)
+	s.runDue(time.Date(2016, 2, 12, 20, 0, 0, 0, time.UTC))
+
+	if len(handler.requests) != 0 {
+		t.Errorf("Expected no requests in dry-run mode, Got %v", handler.requests)
+	}
+
+	if len(logged) != 1 || logged[0].Status != StatusDryRun {
+		t.Fatalf("Expected a dry-run entry, Got %v", logged)
+	}
+
+	if !reflect.DeepEqual([]string{"routable=false"}, logged[0].Actions) {
+		t.Errorf("Expected %v, Got %v", []string{"routable=false"}, logged[0].Actions)
+	}
+
+	if logged[0].Rule != "example-go 0 20 * * *" {
+		t.Errorf("Expected default rule name, Got %s", logged[0].Rule)
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	t.Parallel()
+
+	handler := &fakeHTTPServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	rules := []Rule{
+		{Name: "hourly", App: "example-go", Schedule: "@hourly", Maintenance: &falseVar},
+	}
+	start := time.Date(2016, 2, 12, 10, 30, 0, 0, time.UTC)
+	late := time.Date(2016, 2, 12, 13, 15, 0, 0, time.UTC)
+
+	skip := newTestScheduler(t, server.URL, rules, start)
+	skip.start(start)
+	skip.runDue(late)
+
+	entries := skip.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 skipped entries, Got %v", entries)
+	}
+	for _, e := range entries {
+		if e.Status != StatusSkipped || !e.Missed {
+			t.Errorf("Expected a skipped entry, Got %v", e)
+		}
+	}
+	if len(handler.requests) != 0 {
+		t.Errorf("Expected no requests for skipped runs, Got %v", handler.requests)
+	}
+
+	// A week of suspend is recorded as MaxSkippedEntries entries.
+	suspended := newTestScheduler(t, server.URL, rules, start)
+	suspended.start(start)
+	suspended.runDue(start.AddDate(0, 0, 7))
+
+	entries = suspended.Entries()
+	if len(entries) != MaxSkippedEntries {
+		t.Fatalf("Expected %d skipped entries, Got %d", MaxSkippedEntries, len(entries))
+	}
+	if last := entries[len(entries)-1]; last.Skipped != 7*24-MaxSkippedEntries+1 || entries[0].Skipped != 1 {
+		t.Errorf("Expected the last entry to stand for %d runs, Got %v", 7*24-MaxSkippedEntries+1, last)
+	}
+
+	once := newTestScheduler(t, server.URL, rules, start)
+	once.MissedRunPolicy = RunOnceMissed
+	once.start(start)
+	once.runDue(late)
+
+	entries = once.Entries()
+	if len(entries) != 1 || entries[0].Status != StatusSucceeded || !entries[0].Missed {
+		t.Fatalf("Expected one missed but successful run, Got %v", entries)
+	}
+	if len(handler.requests) != 1 {
+		t.Errorf("Expected 1 request, Got %v", handler.requests)
+	}
+
+	nextExpected := time.Date(2016, 2, 12, 14, 0, 0, 0, time.UTC)
+	if next := once.Next()["hourly"]; !next.Equal(nextExpected) {
+		t.Errorf("Expected %v, Got %v", nextExpected, next)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2016, 2, 12, 10, 30, 0, 0, time.UTC)
+	s := newTestScheduler(t, "http://localhost", []Rule{
+		{App: "example-go", Schedule: "@hourly", Routable: &trueVar},
+	}, start)
+	s.Jitter = 5 * time.Minute
+	s.start(start)
+
+	j := s.jobs[0]
+	if j.fireAt.Before(j.next) || !j.fireAt.Before(j.next.Add(s.Jitter)) {
+		t.Errorf("Expected fire time within jitter of %v, Got %v", j.next, j.fireAt)
+	}
+}
+
+func TestSchedulerRunCancel(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(t, "http://localhost", []Rule{
+		{App: "example-go", Schedule: "@yearly", Routable: &trueVar},
+	}, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Errorf("Expected %v, Got %v", context.Canceled, err)
+	}
+}
+
+func TestSchedulerNextDuringRun(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2016, 2, 12, 19, 30, 0, 0, time.UTC)
+	s := newTestScheduler(t, "http://localhost", []Rule{
+		{App: "example-go", Schedule: "0 20 * * *", Routable: &trueVar},
+	}, start)
+	s.after = func(time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- start
+		return ch
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	expected := time.Date(2016, 2, 12, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		if next := s.Next()["example-go 0 20 * * *"]; !next.Equal(expected) {
+			t.Fatalf("Expected %v, Got %v", expected, next)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected %v, Got %v", context.Canceled, err)
+	}
+}
+
+func TestSchedulerLogSize(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(t, "http://localhost", nil, time.Now())
+	s.LogSize = 2
+
+	for i := 0; i < 3; i++ {
+		s.record(Entry{Rule: fmt.Sprint(i)})
+	}
+
+	entries := s.Entries()
+	if len(entries) != 2 || entries[0].Rule != "1" || entries[1].Rule != "2" {
+		t.Errorf("Expected the last 2 entries, Got %v", entries)
+	}
+}